@@ -9,16 +9,26 @@
 go build ./...
 
 # Test and generate cover profiles
-GOMAXPROCS=1 CI=true ginkgo --cover adapter/db  \
-                                    adapter/rpc \
-                                    core/addr   \
-                                    core/gossip
+GOMAXPROCS=1 CI=true ginkgo --cover adapter/crypto   \
+                                    adapter/db       \
+                                    adapter/keystore \
+                                    adapter/rpc      \
+                                    core/addr        \
+                                    core/gossip      \
+                                    core/logging     \
+                                    core/membership  \
+                                    core/view
 
 # Merge cover profiles into one root cover profile
-covermerge adapter/db/db.coverprofile      \
-           adapter/rpc/rpc.coverprofile    \
-           core/addr/addr.coverprofile     \
-           core/gossip/gossip.coverprofile \
+covermerge adapter/crypto/crypto.coverprofile      \
+           adapter/db/db.coverprofile              \
+           adapter/keystore/keystore.coverprofile  \
+           adapter/rpc/rpc.coverprofile            \
+           core/addr/addr.coverprofile             \
+           core/gossip/gossip.coverprofile         \
+           core/logging/logging.coverprofile       \
+           core/membership/membership.coverprofile \
+           core/view/view.coverprofile             \
            > babble.coverprofile
 
 # Remove auto-generated protobuf files
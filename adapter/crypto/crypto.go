@@ -0,0 +1,51 @@
+package crypto
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/republicprotocol/babble-go/core/gossip"
+)
+
+// Signature schemes implemented by this package. These identifiers are sent
+// over the network and must never be reassigned.
+const (
+	SchemeSecp256k1 = gossip.Scheme(1)
+	SchemeEd25519   = gossip.Scheme(2)
+)
+
+// ErrMalformedSignature is returned when a signature does not have the length
+// required by its scheme.
+var ErrMalformedSignature = errors.New("malformed signature")
+
+// ErrUnsupportedScheme is returned when a signature was produced by a scheme
+// that is not supported by a `gossip.Verifier`.
+type ErrUnsupportedScheme gossip.Scheme
+
+func (err ErrUnsupportedScheme) Error() string {
+	return fmt.Sprintf("unsupported signature scheme %v", uint8(err))
+}
+
+// Verifiers is a `gossip.Verifier` that verifies each signature using the
+// `gossip.Verifier` registered for its scheme. This allows a node to accept
+// several schemes at once, and to move between schemes without requiring all
+// nodes to move at the same time.
+type Verifiers map[gossip.Scheme]gossip.Verifier
+
+// NewVerifier returns a `gossip.Verifier` that supports all signature schemes
+// implemented by this package.
+func NewVerifier() gossip.Verifier {
+	return Verifiers{
+		SchemeSecp256k1: NewSecp256k1Verifier(),
+		SchemeEd25519:   NewEd25519Verifier(),
+	}
+}
+
+// Verify implements the `gossip.Verifier` interface.
+func (verifiers Verifiers) Verify(scheme gossip.Scheme, data []byte, signature []byte) (gossip.Signatory, error) {
+	verifier, ok := verifiers[scheme]
+	if !ok {
+		return nil, ErrUnsupportedScheme(scheme)
+	}
+	return verifier.Verify(scheme, data, signature)
+}
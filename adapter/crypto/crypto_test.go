@@ -0,0 +1,43 @@
+package crypto_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/adapter/crypto"
+
+	"github.com/republicprotocol/babble-go/core/gossip"
+)
+
+var _ = Describe("Verifiers", func() {
+
+	Context("when verifying signatures from several schemes", func() {
+
+		It("should verify each signature using its scheme", func() {
+			secp256k1Key, err := GenerateSecp256k1Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			ed25519Key, err := GenerateEd25519Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			verifier := NewVerifier()
+
+			for _, signer := range []gossip.Signer{NewSecp256k1Signer(secp256k1Key), NewEd25519Signer(ed25519Key)} {
+				data := randomBytes()
+				signature, err := signer.Sign(data)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				_, err = verifier.Verify(signer.Scheme(), data, signature)
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+		})
+
+		It("should return an error for an unsupported scheme", func() {
+			verifier := Verifiers{SchemeEd25519: NewEd25519Verifier()}
+			privateKey, err := GenerateSecp256k1Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			signature, err := NewSecp256k1Signer(privateKey).Sign([]byte("data"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = verifier.Verify(SchemeSecp256k1, []byte("data"), signature)
+			Expect(err).Should(Equal(ErrUnsupportedScheme(SchemeSecp256k1)))
+		})
+	})
+})
@@ -0,0 +1,99 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"github.com/republicprotocol/babble-go/core/gossip"
+	"golang.org/x/crypto/ed25519"
+)
+
+type ed25519Signer struct {
+	privateKey ed25519.PrivateKey
+}
+
+// NewEd25519Signer returns a `gossip.Signer` that uses Ed25519 to sign data.
+// Ed25519 does not support the recovery of public keys, so signatures are the
+// 32 byte public key of the signer followed by the 64 byte Ed25519 signature.
+func NewEd25519Signer(privateKey ed25519.PrivateKey) gossip.Signer {
+	return &ed25519Signer{privateKey}
+}
+
+// Scheme implements the `gossip.Signer` interface.
+func (signer *ed25519Signer) Scheme() gossip.Scheme {
+	return SchemeEd25519
+}
+
+// Sign implements the `gossip.Signer` interface.
+func (signer *ed25519Signer) Sign(data []byte) ([]byte, error) {
+	signature := make([]byte, 0, ed25519.PublicKeySize+ed25519.SignatureSize)
+	signature = append(signature, signer.privateKey.Public().(ed25519.PublicKey)...)
+	return append(signature, ed25519.Sign(signer.privateKey, data)...), nil
+}
+
+type ed25519Verifier struct {
+}
+
+// NewEd25519Verifier returns a `gossip.Verifier` that verifies signatures
+// produced by an Ed25519 `gossip.Signer`. The `gossip.Signatory` extracted
+// from a signature is the public key of the signer.
+func NewEd25519Verifier() gossip.Verifier {
+	return &ed25519Verifier{}
+}
+
+// Verify implements the `gossip.Verifier` interface.
+func (verifier *ed25519Verifier) Verify(scheme gossip.Scheme, data []byte, signature []byte) (gossip.Signatory, error) {
+	if scheme != SchemeEd25519 {
+		return nil, ErrUnsupportedScheme(scheme)
+	}
+	if len(signature) != ed25519.PublicKeySize+ed25519.SignatureSize {
+		return nil, ErrMalformedSignature
+	}
+	publicKey := ed25519.PublicKey(signature[:ed25519.PublicKeySize])
+	if !ed25519.Verify(publicKey, data, signature[ed25519.PublicKeySize:]) {
+		return nil, errors.New("invalid ed25519 signature")
+	}
+	return Ed25519Signatory(publicKey), nil
+}
+
+// Ed25519Signatory returns the `gossip.Signatory` that an Ed25519
+// `gossip.Verifier` extracts from signatures produced by the private key of
+// the `publicKey`.
+func Ed25519Signatory(publicKey ed25519.PublicKey) gossip.Signatory {
+	return gossip.Signatory(append([]byte{}, publicKey...))
+}
+
+// GenerateEd25519Key returns a random Ed25519 private key.
+func GenerateEd25519Key() (ed25519.PrivateKey, error) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	return privateKey, err
+}
+
+// LoadEd25519Key reads a hex encoded Ed25519 private key from a file.
+func LoadEd25519Key(file string) (ed25519.PrivateKey, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Ed25519KeyFromHex(strings.TrimSpace(string(data)))
+}
+
+// SaveEd25519Key writes an Ed25519 private key to a file, hex encoded and
+// readable only by the current user. The file is not encrypted.
+func SaveEd25519Key(file string, privateKey ed25519.PrivateKey) error {
+	return ioutil.WriteFile(file, []byte(hex.EncodeToString(privateKey)), 0600)
+}
+
+// Ed25519KeyFromHex decodes a hex encoded Ed25519 private key.
+func Ed25519KeyFromHex(hexKey string) (ed25519.PrivateKey, error) {
+	data, err := hex.DecodeString(hexKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != ed25519.PrivateKeySize {
+		return nil, errors.New("invalid ed25519 private key length")
+	}
+	return ed25519.PrivateKey(data), nil
+}
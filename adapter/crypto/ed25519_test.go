@@ -0,0 +1,88 @@
+package crypto_test
+
+import (
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/adapter/crypto"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+var _ = Describe("Ed25519", func() {
+
+	AfterEach(func() {
+		os.RemoveAll(keyDir)
+	})
+
+	Context("when signing and verifying data", func() {
+
+		It("should extract the public key of the signer", func() {
+			privateKey, err := GenerateEd25519Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			signer := NewEd25519Signer(privateKey)
+			verifier := NewEd25519Verifier()
+			Expect(signer.Scheme()).Should(Equal(SchemeEd25519))
+
+			for i := 0; i < 10; i++ {
+				data := randomBytes()
+				signature, err := signer.Sign(data)
+				Expect(err).ShouldNot(HaveOccurred())
+
+				signatory, err := verifier.Verify(SchemeEd25519, data, signature)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(signatory).Should(Equal(Ed25519Signatory(privateKey.Public().(ed25519.PublicKey))))
+			}
+		})
+
+		It("should return an error when the data is different", func() {
+			privateKey, err := GenerateEd25519Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			signature, err := NewEd25519Signer(privateKey).Sign([]byte("data"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = NewEd25519Verifier().Verify(SchemeEd25519, []byte("another data"), signature)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return an error when the public key is replaced", func() {
+			privateKey, err := GenerateEd25519Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			anotherKey, err := GenerateEd25519Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			signature, err := NewEd25519Signer(privateKey).Sign([]byte("data"))
+			Expect(err).ShouldNot(HaveOccurred())
+			copy(signature, anotherKey.Public().(ed25519.PublicKey))
+
+			_, err = NewEd25519Verifier().Verify(SchemeEd25519, []byte("data"), signature)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("should return an error when the signature is malformed", func() {
+			_, err := NewEd25519Verifier().Verify(SchemeEd25519, []byte("data"), randomBytes())
+			Expect(err).Should(Equal(ErrMalformedSignature))
+		})
+	})
+
+	Context("when loading keys", func() {
+
+		It("should load a saved key", func() {
+			Expect(os.MkdirAll(keyDir, 0700)).ShouldNot(HaveOccurred())
+			file := filepath.Join(keyDir, "ed25519.key")
+			privateKey, err := GenerateEd25519Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(SaveEd25519Key(file, privateKey)).ShouldNot(HaveOccurred())
+
+			loadedKey, err := LoadEd25519Key(file)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(loadedKey).Should(Equal(privateKey))
+		})
+
+		It("should return an error when the key is malformed", func() {
+			_, err := Ed25519KeyFromHex("abcd")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
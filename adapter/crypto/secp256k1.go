@@ -0,0 +1,93 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/republicprotocol/babble-go/core/gossip"
+)
+
+// secp256k1SignatureLength is the length of a signature, including the
+// recovery identifier.
+const secp256k1SignatureLength = 65
+
+type secp256k1Signer struct {
+	privateKey *ecdsa.PrivateKey
+}
+
+// NewSecp256k1Signer returns a `gossip.Signer` that uses ECDSA over the
+// secp256k1 curve to sign the Keccak256 hash of data. Signatures are 65 bytes
+// and are compatible with signatures produced by Ethereum.
+func NewSecp256k1Signer(privateKey *ecdsa.PrivateKey) gossip.Signer {
+	return &secp256k1Signer{privateKey}
+}
+
+// Scheme implements the `gossip.Signer` interface.
+func (signer *secp256k1Signer) Scheme() gossip.Scheme {
+	return SchemeSecp256k1
+}
+
+// Sign implements the `gossip.Signer` interface.
+func (signer *secp256k1Signer) Sign(data []byte) ([]byte, error) {
+	return ethcrypto.Sign(ethcrypto.Keccak256(data), signer.privateKey)
+}
+
+type secp256k1Verifier struct {
+}
+
+// NewSecp256k1Verifier returns a `gossip.Verifier` that verifies signatures
+// produced by a secp256k1 `gossip.Signer`. The `gossip.Signatory` extracted
+// from a signature is the Ethereum address of the signer.
+func NewSecp256k1Verifier() gossip.Verifier {
+	return &secp256k1Verifier{}
+}
+
+// Verify implements the `gossip.Verifier` interface.
+func (verifier *secp256k1Verifier) Verify(scheme gossip.Scheme, data []byte, signature []byte) (gossip.Signatory, error) {
+	if scheme != SchemeSecp256k1 {
+		return nil, ErrUnsupportedScheme(scheme)
+	}
+	if len(signature) != secp256k1SignatureLength {
+		return nil, ErrMalformedSignature
+	}
+	publicKey, err := ethcrypto.SigToPub(ethcrypto.Keccak256(data), signature)
+	if err != nil {
+		return nil, err
+	}
+	return Secp256k1Signatory(publicKey), nil
+}
+
+// Secp256k1Signatory returns the `gossip.Signatory` that a secp256k1
+// `gossip.Verifier` extracts from signatures produced by the private key of
+// the `publicKey`.
+func Secp256k1Signatory(publicKey *ecdsa.PublicKey) gossip.Signatory {
+	return gossip.Signatory(ethcrypto.PubkeyToAddress(*publicKey).Bytes())
+}
+
+// Secp256k1Address returns the Ethereum address of a `gossip.Signatory` that
+// was extracted by a secp256k1 `gossip.Verifier`.
+func Secp256k1Address(signatory gossip.Signatory) common.Address {
+	return common.BytesToAddress(signatory)
+}
+
+// GenerateSecp256k1Key returns a random secp256k1 private key.
+func GenerateSecp256k1Key() (*ecdsa.PrivateKey, error) {
+	return ethcrypto.GenerateKey()
+}
+
+// LoadSecp256k1Key reads a hex encoded secp256k1 private key from a file.
+func LoadSecp256k1Key(file string) (*ecdsa.PrivateKey, error) {
+	return ethcrypto.LoadECDSA(file)
+}
+
+// SaveSecp256k1Key writes a secp256k1 private key to a file, hex encoded and
+// readable only by the current user. The file is not encrypted.
+func SaveSecp256k1Key(file string, privateKey *ecdsa.PrivateKey) error {
+	return ethcrypto.SaveECDSA(file, privateKey)
+}
+
+// Secp256k1KeyFromHex decodes a hex encoded secp256k1 private key.
+func Secp256k1KeyFromHex(hexKey string) (*ecdsa.PrivateKey, error) {
+	return ethcrypto.HexToECDSA(hexKey)
+}
@@ -0,0 +1,107 @@
+package crypto_test
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/adapter/crypto"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+const keyDir = "./tmp"
+
+var _ = Describe("Secp256k1", func() {
+
+	AfterEach(func() {
+		os.RemoveAll(keyDir)
+	})
+
+	Context("when signing and verifying data", func() {
+
+		It("should extract the ethereum address of the signer", func() {
+			privateKey, err := GenerateSecp256k1Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			signer := NewSecp256k1Signer(privateKey)
+			verifier := NewSecp256k1Verifier()
+
+			for i := 0; i < 10; i++ {
+				data := randomBytes()
+				signature, err := signer.Sign(data)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(len(signature)).Should(Equal(65))
+				Expect(signer.Scheme()).Should(Equal(SchemeSecp256k1))
+
+				signatory, err := verifier.Verify(SchemeSecp256k1, data, signature)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(signatory).Should(Equal(Secp256k1Signatory(&privateKey.PublicKey)))
+				Expect(Secp256k1Address(signatory)).Should(Equal(ethcrypto.PubkeyToAddress(privateKey.PublicKey)))
+			}
+		})
+
+		It("should not extract the signer when the data is different", func() {
+			privateKey, err := GenerateSecp256k1Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			signature, err := NewSecp256k1Signer(privateKey).Sign([]byte("data"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			signatory, err := NewSecp256k1Verifier().Verify(SchemeSecp256k1, []byte("another data"), signature)
+			if err == nil {
+				Expect(signatory).ShouldNot(Equal(Secp256k1Signatory(&privateKey.PublicKey)))
+			}
+		})
+
+		It("should return an error when the scheme is not secp256k1", func() {
+			privateKey, err := GenerateSecp256k1Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			signature, err := NewSecp256k1Signer(privateKey).Sign([]byte("data"))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = NewSecp256k1Verifier().Verify(SchemeEd25519, []byte("data"), signature)
+			Expect(err).Should(Equal(ErrUnsupportedScheme(SchemeEd25519)))
+		})
+
+		It("should return an error when the signature is malformed", func() {
+			_, err := NewSecp256k1Verifier().Verify(SchemeSecp256k1, []byte("data"), randomBytes()[:32])
+			Expect(err).Should(Equal(ErrMalformedSignature))
+		})
+	})
+
+	Context("when loading keys", func() {
+
+		It("should load a saved key", func() {
+			Expect(os.MkdirAll(keyDir, 0700)).ShouldNot(HaveOccurred())
+			file := filepath.Join(keyDir, "secp256k1.key")
+			privateKey, err := GenerateSecp256k1Key()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(SaveSecp256k1Key(file, privateKey)).ShouldNot(HaveOccurred())
+
+			loadedKey, err := LoadSecp256k1Key(file)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(loadedKey.D).Should(Equal(privateKey.D))
+		})
+
+		It("should decode a hex key", func() {
+			privateKey, err := Secp256k1KeyFromHex("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ethcrypto.PubkeyToAddress(privateKey.PublicKey)).Should(Equal(common.HexToAddress("0x71562b71999873DB5b286dF957af199Ec94617F7")))
+		})
+
+		It("should return an error when the key is malformed", func() {
+			_, err := Secp256k1KeyFromHex("not a key")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
+
+func randomBytes() []byte {
+	data := make([]byte, 64)
+	_, err := rand.Read(data)
+	Expect(err).ShouldNot(HaveOccurred())
+
+	return data
+}
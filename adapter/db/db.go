@@ -1,12 +1,18 @@
 package db
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
 	"net"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/republicprotocol/babble-go/core/addr"
 	"github.com/republicprotocol/babble-go/core/gossip"
+	"github.com/republicprotocol/babble-go/core/logging"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
@@ -37,13 +43,37 @@ type Db interface {
 	gossip.Messages
 }
 
+// RecordVersion is the version of the encoding that is used to store an
+// `addr.Record`. It is the first byte of every stored `addr.Record`, so that
+// the encoding can change without breaking `addr.Record` that were stored by
+// earlier versions of the Db.
+const RecordVersion = byte(1)
+
+// numMessageLocks is the number of locks that are used to serialise writes
+// to Messages. Messages with keys that hash to the same lock are serialised
+// with respect to each other.
+const numMessageLocks = 256
+
 type db struct {
-	ldb *leveldb.DB
+	ldb    *leveldb.DB
+	logger logging.Logger
+
+	messageLocks []sync.Mutex
 }
 
-// New Db that uses LevelDB for simple persistent storage.
-func New(ldb *leveldb.DB) Db {
-	return &db{ldb}
+// New Db that uses LevelDB for simple persistent storage. A LevelDB that was
+// written by an earlier version of the Db is migrated using Migrate, and an
+// error is returned if it cannot be migrated. Stored data that cannot be
+// decoded is logged by the `logger`, which can be nil, in which case the
+// default `logging.Logger` is used.
+func New(ldb *leveldb.DB, logger logging.Logger) (Db, error) {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	if err := Migrate(ldb, logger); err != nil {
+		return nil, err
+	}
+	return &db{ldb, logger, make([]sync.Mutex, numMessageLocks)}, nil
 }
 
 // InsertAddr implements the `addr.Addrs` interface.
@@ -55,6 +85,18 @@ func (db *db) InsertAddr(addr net.Addr) error {
 	return db.ldb.Put(keyForAddrs(data), data, nil)
 }
 
+// RemoveAddr implements the `addr.Addrs` interface.
+func (db *db) RemoveAddr(addr net.Addr) error {
+	data, err := json.Marshal(NewAddr(addr.Network(), addr.String()))
+	if err != nil {
+		return err
+	}
+	batch := new(leveldb.Batch)
+	batch.Delete(keyForAddrs(data))
+	batch.Delete(keyForRecords(data))
+	return db.ldb.Write(batch, nil)
+}
+
 // Addrs implements the `addr.Addrs` interface.
 func (db *db) Addrs() ([]net.Addr, error) {
 	iter := db.ldb.NewIterator(&util.Range{Start: append(keyPrefixForAddrs(), keyIterBegin()...), Limit: append(keyPrefixForAddrs(), keyIterEnd()...)}, nil)
@@ -64,6 +106,7 @@ func (db *db) Addrs() ([]net.Addr, error) {
 	for iter.Next() {
 		addr := Addr{}
 		if err := json.Unmarshal(iter.Value(), &addr); err != nil {
+			db.logger.Error("cannot decode addr", logging.Err(err))
 			return nil, err
 		}
 		addrs = append(addrs, addr)
@@ -72,8 +115,68 @@ func (db *db) Addrs() ([]net.Addr, error) {
 	return addrs, iter.Error()
 }
 
+// InsertRecord implements the `addr.Addrs` interface.
+func (db *db) InsertRecord(netAddr net.Addr, record addr.Record) error {
+	data, err := json.Marshal(NewAddr(netAddr.Network(), netAddr.String()))
+	if err != nil {
+		return err
+	}
+	recordData, err := encodeRecord(record)
+	if err != nil {
+		return err
+	}
+	return db.ldb.Put(keyForRecords(data), recordData, nil)
+}
+
+// Record implements the `addr.Addrs` interface.
+func (db *db) Record(netAddr net.Addr) (addr.Record, error) {
+	record := addr.Record{}
+	data, err := json.Marshal(NewAddr(netAddr.Network(), netAddr.String()))
+	if err != nil {
+		return record, err
+	}
+	recordData, err := db.ldb.Get(keyForRecords(data), nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			err = nil
+		}
+		return record, err
+	}
+	if record, err = decodeRecord(recordData); err != nil {
+		db.logger.Error("cannot decode record", logging.Peer(netAddr), logging.Err(err))
+	}
+	return record, err
+}
+
 // InsertMessage implements the `gossip.Messages` interface.
 func (db *db) InsertMessage(message gossip.Message) error {
+	mu := db.messageLock(message.Key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	return db.insertMessage(message)
+}
+
+// InsertMessageIfNewer implements the `gossip.Messages` interface.
+func (db *db) InsertMessageIfNewer(message gossip.Message, resolver gossip.Resolver) (bool, error) {
+	mu := db.messageLock(message.Key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	previousMessage, err := db.Message(message.Key)
+	if err != nil {
+		return false, err
+	}
+	if !gossip.IsNewer(resolver, previousMessage, message) {
+		return false, nil
+	}
+	if err := db.insertMessage(message); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (db *db) insertMessage(message gossip.Message) error {
 	data, err := json.Marshal(message)
 	if err != nil {
 		return err
@@ -81,6 +184,12 @@ func (db *db) InsertMessage(message gossip.Message) error {
 	return db.ldb.Put(keyForMessages(message.Key), data, nil)
 }
 
+func (db *db) messageLock(key []byte) *sync.Mutex {
+	hash := fnv.New32a()
+	hash.Write(key)
+	return &db.messageLocks[hash.Sum32()%numMessageLocks]
+}
+
 // Message implements the `gossip.Messages` interface.
 func (db *db) Message(key []byte) (gossip.Message, error) {
 	message := gossip.Message{}
@@ -91,11 +200,105 @@ func (db *db) Message(key []byte) (gossip.Message, error) {
 		}
 		return message, err
 	}
-	err = json.Unmarshal(data, &message)
-	return message, err
+	if err := json.Unmarshal(data, &message); err != nil {
+		db.logger.Error("cannot decode message", logging.Key(key), logging.Err(err))
+		return message, err
+	}
+	return message, nil
 }
 
-func keyPrefixForMessages() []byte {
+// Messages implements the `gossip.Messages` interface.
+func (db *db) Messages() ([]gossip.Message, error) {
+	messages, _, err := db.MessagesWithPrefix(nil, nil, 0)
+	return messages, err
+}
+
+// MessagesWithPrefix implements the `gossip.Messages` interface.
+func (db *db) MessagesWithPrefix(prefix, cursor []byte, limit int) ([]gossip.Message, []byte, error) {
+	iterRange := util.BytesPrefix(keyForMessages(prefix))
+	if start := keyForMessages(cursor); bytes.Compare(start, iterRange.Start) > 0 {
+		iterRange.Start = start
+	}
+	iter := db.ldb.NewIterator(iterRange, nil)
+	defer iter.Release()
+
+	messages := make([]gossip.Message, 0)
+	for iter.Next() {
+		if limit > 0 && len(messages) >= limit {
+			return messages, gossip.NextCursor(messages[len(messages)-1].Key), iter.Error()
+		}
+		message := gossip.Message{}
+		if err := json.Unmarshal(iter.Value(), &message); err != nil {
+			db.logger.Error("cannot decode message", logging.Key(iter.Key()[len(keyPrefixForMessages()):]), logging.Err(err))
+			return nil, nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return messages, nil, iter.Error()
+}
+
+// Migrate Messages that were stored under the Keccak256 hash of their key, by
+// earlier versions of the Db, so that they are stored under their key. It is
+// called by New, so it only needs to be called when migrating a LevelDB
+// without opening a Db. Migrating a LevelDB that has no such Messages does
+// nothing. Messages that cannot be decoded are
+// logged by the `logger`, which can be nil, in which case the default
+// `logging.Logger` is used.
+func Migrate(ldb *leveldb.DB, logger logging.Logger) error {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+
+	iter := ldb.NewIterator(&util.Range{Start: append(keyPrefixForHashedMessages(), keyIterBegin()...), Limit: append(keyPrefixForHashedMessages(), keyIterEnd()...)}, nil)
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		message := gossip.Message{}
+		if err := json.Unmarshal(iter.Value(), &message); err != nil {
+			logger.Error("cannot decode message", logging.Err(err))
+			return err
+		}
+		batch.Put(keyForMessages(message.Key), iter.Value())
+		batch.Delete(append([]byte{}, iter.Key()...))
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	return ldb.Write(batch, nil)
+}
+
+func encodeRecord(record addr.Record) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{RecordVersion}, data...), nil
+}
+
+func decodeRecord(data []byte) (addr.Record, error) {
+	record := addr.Record{}
+	if len(data) == 0 {
+		return record, errors.New("cannot decode record: empty data")
+	}
+
+	switch data[0] {
+	case '{':
+		// Records that were stored before the encoding was versioned are
+		// JSON objects without a version
+		err := json.Unmarshal(data, &record)
+		return record, err
+	case RecordVersion:
+		err := json.Unmarshal(data[1:], &record)
+		return record, err
+	default:
+		return record, fmt.Errorf("cannot decode record: unsupported version %v", data[0])
+	}
+}
+
+func keyPrefixForHashedMessages() []byte {
 	return []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
 }
 
@@ -103,14 +306,28 @@ func keyPrefixForAddrs() []byte {
 	return []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}
 }
 
+func keyPrefixForMessages() []byte {
+	return []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02}
+}
+
+func keyPrefixForRecords() []byte {
+	return []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03}
+}
+
+// keyForMessages stores Messages under their key, instead of a hash of their
+// key, so that they are iterated in order of their key.
 func keyForMessages(key []byte) []byte {
-	return append(keyPrefixForMessages(), crypto.Keccak256(key)...)
+	return append(keyPrefixForMessages(), key...)
 }
 
 func keyForAddrs(key []byte) []byte {
 	return append(keyPrefixForAddrs(), crypto.Keccak256(key)...)
 }
 
+func keyForRecords(key []byte) []byte {
+	return append(keyPrefixForRecords(), crypto.Keccak256(key)...)
+}
+
 func keyIterBegin() []byte {
 	return []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
 }
@@ -1,16 +1,22 @@
 package db_test
 
 import (
+	"encoding/json"
 	"math/rand"
+	"net"
 	"os"
 	"reflect"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/republicprotocol/babble-go/adapter/db"
 
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/republicprotocol/babble-go/core/addr"
 	"github.com/republicprotocol/babble-go/core/gossip"
+	"github.com/republicprotocol/babble-go/core/logging"
 	"github.com/syndtr/goleveldb/leveldb"
 )
 
@@ -20,6 +26,24 @@ const (
 	messageDbFile = "./tmp/messages"
 )
 
+// errorLogger records the messages of the errors that are logged to it.
+type errorLogger struct {
+	mu     *sync.Mutex
+	errors *[]string
+}
+
+func (logger errorLogger) Debug(msg string, fields ...logging.Field) {}
+
+func (logger errorLogger) Info(msg string, fields ...logging.Field) {}
+
+func (logger errorLogger) Warn(msg string, fields ...logging.Field) {}
+
+func (logger errorLogger) Error(msg string, fields ...logging.Field) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	*logger.errors = append(*logger.errors, msg)
+}
+
 var _ = Describe("LevelDB storage", func() {
 
 	BeforeEach(func() {
@@ -35,7 +59,8 @@ var _ = Describe("LevelDB storage", func() {
 			ldb, err := leveldb.OpenFile(addrDbFile, nil)
 			Expect(err).ShouldNot(HaveOccurred())
 			defer ldb.Close()
-			store := New(ldb)
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
 
 			addrs := testAddresses()
 			lookup := map[string]bool{}
@@ -62,12 +87,123 @@ var _ = Describe("LevelDB storage", func() {
 		})
 	})
 
+	Context("when removing an address", func() {
+		It("should remove the address and its record", func() {
+			ldb, err := leveldb.OpenFile(addrDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			removed, kept := NewAddr("tcp", "10.0.1.1"), NewAddr("tcp", "10.0.1.2")
+			record := addr.Record{LastSeen: time.Now().Round(0), Failures: 1}
+			for _, addr := range []Addr{removed.(Addr), kept.(Addr)} {
+				Expect(store.InsertAddr(addr)).ShouldNot(HaveOccurred())
+				Expect(store.InsertRecord(addr, record)).ShouldNot(HaveOccurred())
+			}
+			Expect(store.RemoveAddr(removed)).ShouldNot(HaveOccurred())
+
+			addrs, err := store.Addrs()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrs).Should(ConsistOf(kept))
+			stored, err := store.Record(removed)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored).Should(Equal(addr.Record{}))
+			stored, err = store.Record(kept)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.LastSeen.Equal(record.LastSeen)).Should(BeTrue())
+			Expect(stored.Failures).Should(Equal(record.Failures))
+		})
+	})
+
+	Context("when storing the record of an address", func() {
+		It("should store all of the metadata", func() {
+			ldb, err := leveldb.OpenFile(addrDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			peer := NewAddr("tcp", "10.0.1.1")
+			now := time.Now().Round(0)
+			record := addr.Record{
+				Identity:        []byte("identity"),
+				FirstSeen:       now.Add(-time.Hour),
+				LastSeen:        now,
+				LastSent:        now.Add(-time.Minute),
+				Failures:        2,
+				ProtocolVersion: 1,
+				Tags:            []string{"seed", "validator"},
+			}
+			Expect(store.InsertAddr(peer)).ShouldNot(HaveOccurred())
+			Expect(store.InsertRecord(peer, record)).ShouldNot(HaveOccurred())
+
+			stored, err := store.Record(peer)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.Identity).Should(Equal(record.Identity))
+			Expect(stored.FirstSeen.Equal(record.FirstSeen)).Should(BeTrue())
+			Expect(stored.LastSeen.Equal(record.LastSeen)).Should(BeTrue())
+			Expect(stored.LastSent.Equal(record.LastSent)).Should(BeTrue())
+			Expect(stored.Failures).Should(Equal(record.Failures))
+			Expect(stored.ProtocolVersion).Should(Equal(record.ProtocolVersion))
+			Expect(stored.Tags).Should(Equal(record.Tags))
+		})
+
+		It("should prefix the stored record with its version", func() {
+			ldb, err := leveldb.OpenFile(addrDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			peer := NewAddr("tcp", "10.0.1.1")
+			Expect(store.InsertRecord(peer, addr.Record{Failures: 1})).ShouldNot(HaveOccurred())
+
+			data, err := ldb.Get(keyForRecord(peer), nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(data[0]).Should(Equal(RecordVersion))
+		})
+
+		It("should read records that were stored without a version", func() {
+			ldb, err := leveldb.OpenFile(addrDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			peer := NewAddr("tcp", "10.0.1.1")
+			Expect(ldb.Put(keyForRecord(peer), []byte(`{"lastSeen":"2018-10-01T00:00:00Z","failures":2}`), nil)).ShouldNot(HaveOccurred())
+
+			stored, err := store.Record(peer)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.LastSeen.Equal(time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC))).Should(BeTrue())
+			Expect(stored.Failures).Should(Equal(2))
+		})
+
+		It("should not read records with an unsupported version", func() {
+			ldb, err := leveldb.OpenFile(addrDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+			logger := errorLogger{new(sync.Mutex), new([]string)}
+			store, err := New(ldb, logger)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			peer := NewAddr("tcp", "10.0.1.1")
+			Expect(ldb.Put(keyForRecord(peer), []byte{RecordVersion + 1}, nil)).ShouldNot(HaveOccurred())
+
+			_, err = store.Record(peer)
+			Expect(err).Should(HaveOccurred())
+			Expect(*logger.errors).Should(Equal([]string{"cannot decode record"}))
+		})
+	})
+
 	Context("when storing new messages ", func() {
 		It("should store new message ", func() {
 			ldb, err := leveldb.OpenFile(messageDbFile, nil)
 			Expect(err).ShouldNot(HaveOccurred())
 			defer ldb.Close()
-			store := New(ldb)
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
 
 			messages := testMessages()
 			for _, message := range messages {
@@ -80,6 +216,168 @@ var _ = Describe("LevelDB storage", func() {
 				Expect(reflect.DeepEqual(message, msg)).Should(BeTrue())
 			}
 		})
+
+		It("should not open a Db whose messages cannot be migrated", func() {
+			ldb, err := leveldb.OpenFile(messageDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+
+			Expect(ldb.Put(append(make([]byte, 8), crypto.Keccak256([]byte("key"))...), []byte("corrupt"), nil)).ShouldNot(HaveOccurred())
+			_, err = New(ldb, errorLogger{new(sync.Mutex), new([]string)})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when listing messages ", func() {
+		It("should return the latest message for every key", func() {
+			ldb, err := leveldb.OpenFile(messageDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			latest := map[string]gossip.Message{}
+			for _, message := range testMessages() {
+				Expect(store.InsertMessage(message)).ShouldNot(HaveOccurred())
+				latest[string(message.Key)] = message
+			}
+
+			messages, err := store.Messages()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(messages)).Should(Equal(len(latest)))
+			for _, message := range messages {
+				Expect(reflect.DeepEqual(latest[string(message.Key)], message)).Should(BeTrue())
+			}
+		})
+	})
+
+	Context("when inserting messages that are newer", func() {
+		It("should only insert messages with a higher nonce", func() {
+			ldb, err := leveldb.OpenFile(messageDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			message := gossip.NewMessage(2, []byte("key"), []byte("value"), nil)
+			ok, err := store.InsertMessageIfNewer(message, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(ok).Should(BeTrue())
+
+			for _, nonce := range []uint64{1, 2} {
+				ok, err := store.InsertMessageIfNewer(gossip.NewMessage(nonce, []byte("key"), []byte("another value"), nil), nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(ok).Should(BeFalse())
+			}
+
+			stored, err := store.Message(message.Key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.Value).Should(Equal(message.Value))
+		})
+
+		It("should keep the highest nonce when inserting concurrently", func() {
+			ldb, err := leveldb.OpenFile(messageDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			nonces := rand.Perm(100)
+			accepted := make([]bool, len(nonces))
+			var wg sync.WaitGroup
+			for i := range nonces {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					ok, err := store.InsertMessageIfNewer(gossip.NewMessage(uint64(nonces[i]+1), []byte("key"), []byte("value"), nil), nil)
+					Expect(err).ShouldNot(HaveOccurred())
+					accepted[i] = ok
+				}(i)
+			}
+			wg.Wait()
+
+			stored, err := store.Message([]byte("key"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.Nonce).Should(Equal(uint64(len(nonces))))
+			Expect(accepted).Should(ContainElement(BeTrue()))
+		})
+	})
+
+	Context("when listing messages with a prefix", func() {
+		It("should return the messages with the prefix in order of key", func() {
+			ldb, err := leveldb.OpenFile(messageDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			for _, key := range []string{"b/2", "a/1", "b/1", "c", "b/3", "b"} {
+				Expect(store.InsertMessage(gossip.NewMessage(1, []byte(key), nil, nil))).ShouldNot(HaveOccurred())
+			}
+
+			messages, cursor, err := store.MessagesWithPrefix([]byte("b/"), nil, 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(cursor).Should(BeNil())
+			Expect(keysOf(messages)).Should(Equal([]string{"b/1", "b/2", "b/3"}))
+
+			messages, cursor, err = store.MessagesWithPrefix(nil, nil, 0)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(cursor).Should(BeNil())
+			Expect(keysOf(messages)).Should(Equal([]string{"a/1", "b", "b/1", "b/2", "b/3", "c"}))
+		})
+
+		It("should paginate the messages using the cursor", func() {
+			ldb, err := leveldb.OpenFile(messageDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			for _, key := range []string{"b/2", "a/1", "b/1", "c", "b/3", "b", "b/4"} {
+				Expect(store.InsertMessage(gossip.NewMessage(1, []byte(key), nil, nil))).ShouldNot(HaveOccurred())
+			}
+
+			pages := [][]string{}
+			var cursor []byte
+			for {
+				var messages []gossip.Message
+				messages, cursor, err = store.MessagesWithPrefix([]byte("b"), cursor, 2)
+				Expect(err).ShouldNot(HaveOccurred())
+				pages = append(pages, keysOf(messages))
+				if cursor == nil {
+					break
+				}
+			}
+			Expect(pages).Should(Equal([][]string{{"b", "b/1"}, {"b/2", "b/3"}, {"b/4"}}))
+		})
+	})
+
+	Context("when migrating messages stored under the hash of their key", func() {
+		It("should migrate them when the Db is opened", func() {
+			ldb, err := leveldb.OpenFile(messageDbFile, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer ldb.Close()
+
+			latest := map[string]gossip.Message{}
+			for _, message := range testMessages() {
+				data, err := json.Marshal(message)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(ldb.Put(append(make([]byte, 8), crypto.Keccak256(message.Key)...), data, nil)).ShouldNot(HaveOccurred())
+				latest[string(message.Key)] = message
+			}
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			messages, err := store.Messages()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(messages)).Should(Equal(len(latest)))
+			for _, message := range latest {
+				msg, err := store.Message(message.Key)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(reflect.DeepEqual(message, msg)).Should(BeTrue())
+			}
+		})
 	})
 
 	Context("when reading messages ", func() {
@@ -87,7 +385,8 @@ var _ = Describe("LevelDB storage", func() {
 			ldb, err := leveldb.OpenFile(messageDbFile, nil)
 			Expect(err).ShouldNot(HaveOccurred())
 			defer ldb.Close()
-			store := New(ldb)
+			store, err := New(ldb, nil)
+			Expect(err).ShouldNot(HaveOccurred())
 
 			messages := testMessages()
 			for _, message := range messages {
@@ -140,6 +439,22 @@ func testMessages() []gossip.Message {
 	return messages
 }
 
+// keyForRecord returns the LevelDB key under which the Db stores the record of
+// an address.
+func keyForRecord(addr net.Addr) []byte {
+	data, err := json.Marshal(addr)
+	Expect(err).ShouldNot(HaveOccurred())
+	return append([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03}, crypto.Keccak256(data)...)
+}
+
+func keysOf(messages []gossip.Message) []string {
+	keys := make([]string, len(messages))
+	for i := range messages {
+		keys[i] = string(messages[i].Key)
+	}
+	return keys
+}
+
 func randomBytes() []byte {
 	length := rand.Intn(65)
 	data := make([]byte, length)
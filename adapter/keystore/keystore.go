@@ -0,0 +1,366 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/republicprotocol/babble-go/adapter/crypto"
+	"github.com/republicprotocol/babble-go/core/gossip"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version of the keystore file format.
+const Version = 1
+
+// Scrypt parameters. The standard parameters use 256MB of memory and take
+// around one second to unlock a key on a modern CPU. The light parameters use
+// 4MB of memory and should only be used when unlocking keys must be fast.
+const (
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+	LightScryptN    = 1 << 12
+	LightScryptP    = 6
+
+	scryptR     = 8
+	scryptDKLen = 32
+)
+
+// ErrWrongPassphrase is returned when a key cannot be decrypted using the
+// given passphrase.
+var ErrWrongPassphrase = errors.New("could not decrypt key with given passphrase")
+
+// A Key is an unlocked private key, and the signature scheme that it is used
+// with. Secp256k1 private keys are 32 bytes and Ed25519 private keys are 64
+// bytes.
+type Key struct {
+	Scheme     gossip.Scheme
+	PrivateKey []byte
+}
+
+// GenerateKey returns a random Key for a signature scheme.
+func GenerateKey(scheme gossip.Scheme) (Key, error) {
+	switch scheme {
+	case crypto.SchemeSecp256k1:
+		privateKey, err := crypto.GenerateSecp256k1Key()
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{scheme, ethcrypto.FromECDSA(privateKey)}, nil
+	case crypto.SchemeEd25519:
+		privateKey, err := crypto.GenerateEd25519Key()
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{scheme, privateKey}, nil
+	default:
+		return Key{}, crypto.ErrUnsupportedScheme(scheme)
+	}
+}
+
+// Signer returns a `gossip.Signer` that signs using the Key.
+func (key Key) Signer() (gossip.Signer, error) {
+	switch key.Scheme {
+	case crypto.SchemeSecp256k1:
+		privateKey, err := ethcrypto.ToECDSA(key.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.NewSecp256k1Signer(privateKey), nil
+	case crypto.SchemeEd25519:
+		if len(key.PrivateKey) != ed25519.PrivateKeySize {
+			return nil, errors.New("invalid ed25519 private key length")
+		}
+		return crypto.NewEd25519Signer(ed25519.PrivateKey(key.PrivateKey)), nil
+	default:
+		return nil, crypto.ErrUnsupportedScheme(key.Scheme)
+	}
+}
+
+// Signatory returns the `gossip.Signatory` that a `gossip.Verifier` extracts
+// from signatures produced by the Key.
+func (key Key) Signatory() (gossip.Signatory, error) {
+	switch key.Scheme {
+	case crypto.SchemeSecp256k1:
+		privateKey, err := ethcrypto.ToECDSA(key.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Secp256k1Signatory(&privateKey.PublicKey), nil
+	case crypto.SchemeEd25519:
+		if len(key.PrivateKey) != ed25519.PrivateKeySize {
+			return nil, errors.New("invalid ed25519 private key length")
+		}
+		return crypto.Ed25519Signatory(ed25519.PrivateKey(key.PrivateKey).Public().(ed25519.PublicKey)), nil
+	default:
+		return nil, crypto.ErrUnsupportedScheme(key.Scheme)
+	}
+}
+
+// A Keystore stores one Key in a file. The Key is encrypted with AES-256-GCM
+// using a key that is derived from a passphrase using scrypt.
+type Keystore interface {
+
+	// Create a random Key for the signature scheme and store it. It returns
+	// an error if a Key is already stored.
+	Create(scheme gossip.Scheme, passphrase string) (Key, error)
+
+	// Import an existing Key and store it. It returns an error if a Key is
+	// already stored.
+	Import(key Key, passphrase string) error
+
+	// Unlock the stored Key.
+	Unlock(passphrase string) (Key, error)
+
+	// Signer unlocks the stored Key and returns a `gossip.Signer` that signs
+	// using it.
+	Signer(passphrase string) (gossip.Signer, error)
+
+	// Rotate replaces the stored Key with a random Key for the same signature
+	// scheme. The previous Key is kept in a file named after the Keystore
+	// file and the hex encoded `gossip.Signatory` of the previous Key, so
+	// that it can still be unlocked. It returns the previous Key and the new
+	// Key.
+	Rotate(passphrase string) (Key, Key, error)
+
+	// ChangePassphrase encrypts the stored Key using a new passphrase.
+	ChangePassphrase(passphrase, newPassphrase string) error
+
+	// Export the stored Key as an unencrypted hex string. The string can be
+	// loaded using `crypto.Secp256k1KeyFromHex`, or
+	// `crypto.Ed25519KeyFromHex`, depending on the signature scheme.
+	Export(passphrase string) (string, error)
+}
+
+type keystore struct {
+	file    string
+	scryptN int
+	scryptP int
+}
+
+// New returns a Keystore that stores a Key in the `file`. The `scryptN` and
+// `scryptP` parameters are used when encrypting the Key.
+func New(file string, scryptN, scryptP int) Keystore {
+	return &keystore{
+		file:    file,
+		scryptN: scryptN,
+		scryptP: scryptP,
+	}
+}
+
+// Create implements the Keystore interface.
+func (keystore *keystore) Create(scheme gossip.Scheme, passphrase string) (Key, error) {
+	key, err := GenerateKey(scheme)
+	if err != nil {
+		return Key{}, err
+	}
+	return key, keystore.Import(key, passphrase)
+}
+
+// Import implements the Keystore interface.
+func (keystore *keystore) Import(key Key, passphrase string) error {
+	data, err := keystore.encrypt(key, passphrase)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(keystore.file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Unlock implements the Keystore interface.
+func (keystore *keystore) Unlock(passphrase string) (Key, error) {
+	data, err := ioutil.ReadFile(keystore.file)
+	if err != nil {
+		return Key{}, err
+	}
+	return decrypt(data, passphrase)
+}
+
+// Signer implements the Keystore interface.
+func (keystore *keystore) Signer(passphrase string) (gossip.Signer, error) {
+	key, err := keystore.Unlock(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return key.Signer()
+}
+
+// Rotate implements the Keystore interface.
+func (keystore *keystore) Rotate(passphrase string) (Key, Key, error) {
+	data, err := ioutil.ReadFile(keystore.file)
+	if err != nil {
+		return Key{}, Key{}, err
+	}
+	previousKey, err := decrypt(data, passphrase)
+	if err != nil {
+		return Key{}, Key{}, err
+	}
+	previousSignatory, err := previousKey.Signatory()
+	if err != nil {
+		return Key{}, Key{}, err
+	}
+	key, err := GenerateKey(previousKey.Scheme)
+	if err != nil {
+		return Key{}, Key{}, err
+	}
+
+	if err := writeFile(fmt.Sprintf("%v.%x", keystore.file, []byte(previousSignatory)), data); err != nil {
+		return Key{}, Key{}, err
+	}
+	data, err = keystore.encrypt(key, passphrase)
+	if err != nil {
+		return Key{}, Key{}, err
+	}
+	return previousKey, key, writeFile(keystore.file, data)
+}
+
+// ChangePassphrase implements the Keystore interface.
+func (keystore *keystore) ChangePassphrase(passphrase, newPassphrase string) error {
+	key, err := keystore.Unlock(passphrase)
+	if err != nil {
+		return err
+	}
+	data, err := keystore.encrypt(key, newPassphrase)
+	if err != nil {
+		return err
+	}
+	return writeFile(keystore.file, data)
+}
+
+// Export implements the Keystore interface.
+func (keystore *keystore) Export(passphrase string) (string, error) {
+	key, err := keystore.Unlock(passphrase)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key.PrivateKey), nil
+}
+
+type keyFile struct {
+	Version int           `json:"version"`
+	Scheme  gossip.Scheme `json:"scheme"`
+	Crypto  keyFileCrypto `json:"crypto"`
+}
+
+type keyFileCrypto struct {
+	KDF        string `json:"kdf"`
+	ScryptN    int    `json:"n"`
+	ScryptR    int    `json:"r"`
+	ScryptP    int    `json:"p"`
+	Salt       string `json:"salt"`
+	Cipher     string `json:"cipher"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+func (keystore *keystore) encrypt(key Key, passphrase string) ([]byte, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt, keystore.scryptN, scryptR, keystore.scryptP)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	ciphertext := aead.Seal(nil, nonce, key.PrivateKey, additionalData(Version, key.Scheme))
+	return json.Marshal(keyFile{
+		Version: Version,
+		Scheme:  key.Scheme,
+		Crypto: keyFileCrypto{
+			KDF:        "scrypt",
+			ScryptN:    keystore.scryptN,
+			ScryptR:    scryptR,
+			ScryptP:    keystore.scryptP,
+			Salt:       hex.EncodeToString(salt),
+			Cipher:     "aes-256-gcm",
+			Nonce:      hex.EncodeToString(nonce),
+			Ciphertext: hex.EncodeToString(ciphertext),
+		},
+	})
+}
+
+func decrypt(data []byte, passphrase string) (Key, error) {
+	file := keyFile{}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Key{}, err
+	}
+	if file.Version != Version {
+		return Key{}, fmt.Errorf("unsupported keystore version %v", file.Version)
+	}
+	if file.Crypto.KDF != "scrypt" || file.Crypto.Cipher != "aes-256-gcm" {
+		return Key{}, fmt.Errorf("unsupported keystore encryption %v with %v", file.Crypto.Cipher, file.Crypto.KDF)
+	}
+	salt, err := hex.DecodeString(file.Crypto.Salt)
+	if err != nil {
+		return Key{}, err
+	}
+	nonce, err := hex.DecodeString(file.Crypto.Nonce)
+	if err != nil {
+		return Key{}, err
+	}
+	ciphertext, err := hex.DecodeString(file.Crypto.Ciphertext)
+	if err != nil {
+		return Key{}, err
+	}
+
+	aead, err := newAEAD(passphrase, salt, file.Crypto.ScryptN, file.Crypto.ScryptR, file.Crypto.ScryptP)
+	if err != nil {
+		return Key{}, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return Key{}, errors.New("invalid keystore nonce length")
+	}
+	privateKey, err := aead.Open(nil, nonce, ciphertext, additionalData(file.Version, file.Scheme))
+	if err != nil {
+		return Key{}, ErrWrongPassphrase
+	}
+	return Key{file.Scheme, privateKey}, nil
+}
+
+func newAEAD(passphrase string, salt []byte, scryptN, scryptR, scryptP int) (cipher.AEAD, error) {
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// additionalData binds the version and the signature scheme to the encrypted
+// Key, so that neither can be modified without failing to decrypt the Key.
+func additionalData(version int, scheme gossip.Scheme) []byte {
+	return []byte{byte(version), byte(scheme)}
+}
+
+// writeFile atomically replaces the contents of a file by writing to a
+// temporary file and renaming it.
+func writeFile(file string, data []byte) error {
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
@@ -0,0 +1,13 @@
+package keystore_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestKeystore(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Keystore Suite")
+}
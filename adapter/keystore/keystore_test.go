@@ -0,0 +1,148 @@
+package keystore_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/adapter/keystore"
+
+	"github.com/republicprotocol/babble-go/adapter/crypto"
+	"github.com/republicprotocol/babble-go/core/gossip"
+)
+
+const keystoreDir = "./tmp"
+
+var _ = Describe("Keystore", func() {
+
+	newKeystore := func() Keystore {
+		Expect(os.MkdirAll(keystoreDir, 0700)).ShouldNot(HaveOccurred())
+		return New(filepath.Join(keystoreDir, "key.json"), LightScryptN, LightScryptP)
+	}
+
+	AfterEach(func() {
+		os.RemoveAll(keystoreDir)
+	})
+
+	for _, scheme := range []gossip.Scheme{crypto.SchemeSecp256k1, crypto.SchemeEd25519} {
+		scheme := scheme
+
+		Context(fmt.Sprintf("when storing keys for scheme %v", scheme), func() {
+
+			It("should unlock a created key with the same passphrase", func() {
+				keystore := newKeystore()
+				key, err := keystore.Create(scheme, "passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(key.Scheme).Should(Equal(scheme))
+
+				unlockedKey, err := keystore.Unlock("passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(unlockedKey).Should(Equal(key))
+
+				_, err = keystore.Unlock("another passphrase")
+				Expect(err).Should(Equal(ErrWrongPassphrase))
+			})
+
+			It("should not store the key in plaintext", func() {
+				keystore := newKeystore()
+				key, err := keystore.Create(scheme, "passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+				exported, err := keystore.Export("passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				data, err := ioutil.ReadFile(filepath.Join(keystoreDir, "key.json"))
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(strings.Contains(string(data), exported)).Should(BeFalse())
+				Expect(strings.Contains(string(data), string(key.PrivateKey))).Should(BeFalse())
+			})
+
+			It("should not overwrite an existing key", func() {
+				keystore := newKeystore()
+				_, err := keystore.Create(scheme, "passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+				_, err = keystore.Create(scheme, "passphrase")
+				Expect(err).Should(HaveOccurred())
+			})
+
+			It("should return a signer whose signatures are verified as the key", func() {
+				keystore := newKeystore()
+				key, err := keystore.Create(scheme, "passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+				signer, err := keystore.Signer("passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(signer.Scheme()).Should(Equal(scheme))
+
+				signature, err := signer.Sign([]byte("data"))
+				Expect(err).ShouldNot(HaveOccurred())
+				signatory, err := crypto.NewVerifier().Verify(scheme, []byte("data"), signature)
+				Expect(err).ShouldNot(HaveOccurred())
+				expectedSignatory, err := key.Signatory()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(signatory).Should(Equal(expectedSignatory))
+			})
+
+			It("should rotate the key and keep the previous key", func() {
+				keystore := newKeystore()
+				key, err := keystore.Create(scheme, "passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+
+				previousKey, newKey, err := keystore.Rotate("passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(previousKey).Should(Equal(key))
+				Expect(newKey.Scheme).Should(Equal(scheme))
+				Expect(newKey.PrivateKey).ShouldNot(Equal(key.PrivateKey))
+
+				unlockedKey, err := keystore.Unlock("passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(unlockedKey).Should(Equal(newKey))
+
+				signatory, err := key.Signatory()
+				Expect(err).ShouldNot(HaveOccurred())
+				previousKeystore := New(fmt.Sprintf("%v.%x", filepath.Join(keystoreDir, "key.json"), []byte(signatory)), LightScryptN, LightScryptP)
+				unlockedKey, err = previousKeystore.Unlock("passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(unlockedKey).Should(Equal(key))
+			})
+
+			It("should change the passphrase", func() {
+				keystore := newKeystore()
+				key, err := keystore.Create(scheme, "passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(keystore.ChangePassphrase("passphrase", "new passphrase")).ShouldNot(HaveOccurred())
+
+				_, err = keystore.Unlock("passphrase")
+				Expect(err).Should(Equal(ErrWrongPassphrase))
+				unlockedKey, err := keystore.Unlock("new passphrase")
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(unlockedKey).Should(Equal(key))
+			})
+		})
+	}
+
+	Context("when exporting keys", func() {
+
+		It("should export a key that can be loaded by the crypto adapter", func() {
+			keystore := newKeystore()
+			key, err := keystore.Create(crypto.SchemeEd25519, "passphrase")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			exported, err := keystore.Export("passphrase")
+			Expect(err).ShouldNot(HaveOccurred())
+			privateKey, err := crypto.Ed25519KeyFromHex(exported)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect([]byte(privateKey)).Should(Equal(key.PrivateKey))
+		})
+	})
+
+	Context("when importing keys", func() {
+
+		It("should return an error for an unsupported scheme", func() {
+			_, err := newKeystore().Create(gossip.Scheme(0), "passphrase")
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+})
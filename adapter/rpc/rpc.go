@@ -2,12 +2,38 @@ package rpc
 
 import (
 	"context"
+	"errors"
 	"net"
+	"sync"
 
 	"github.com/republicprotocol/babble-go/core/gossip"
+	"github.com/republicprotocol/babble-go/core/logging"
+	"github.com/republicprotocol/babble-go/core/membership"
+	"github.com/republicprotocol/babble-go/core/view"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
+// ErrNoMembership is returned when a Service receives a ping, but was not
+// created with a `membership.Server`.
+var ErrNoMembership = errors.New("membership is not supported")
+
+// ErrAlreadyStarted is returned when a Service that is already serving is
+// started again.
+var ErrAlreadyStarted = errors.New("service is already started")
+
+// ErrNoView is returned when a Service receives a shuffle, but was not created
+// with a `view.Server`.
+var ErrNoView = errors.New("view is not supported")
+
+// ListenAddrKey is the gRPC metadata key under which a client advertises the
+// address that it listens on. Only the port of the advertised address is used,
+// the host is always taken from the connection.
+const ListenAddrKey = "babble-listen-addr"
+
 // Dialer is used to open a connection to a gRPC server.
 type Dialer interface {
 
@@ -27,12 +53,54 @@ type Caller interface {
 type client struct {
 	Dialer
 	Caller
+
+	listenAddr net.Addr
+	membership membership.Server
+	logger     logging.Logger
 }
 
 // NewClient returns an implementation of the `gossip.Client` interface that
+// uses gRPC to invoke RPCs. Failed RPCs are logged by the `logger`, which can
+// be nil, in which case the default `logging.Logger` is used.
+func NewClient(dialer Dialer, caller Caller, logger logging.Logger) gossip.Client {
+	return newClient(dialer, caller, nil, nil, logger)
+}
+
+// NewClientWithListenAddr returns an implementation of the `gossip.Client`
+// interface that uses gRPC to invoke RPCs, and that advertises the
+// `listenAddr` with every Message that it sends, so that receivers can learn
+// where to reach it.
+func NewClientWithListenAddr(dialer Dialer, caller Caller, listenAddr net.Addr, logger logging.Logger) gossip.Client {
+	return newClient(dialer, caller, listenAddr, nil, logger)
+}
+
+// NewPiggybackingClient returns an implementation of the `gossip.Client`
+// interface that uses gRPC to invoke RPCs, like NewClientWithListenAddr, and
+// that piggybacks the Updates of the `membership` onto every Send and Sync.
+// Updates that are piggybacked onto the responses are applied to the
+// `membership`. The `listenAddr` can be nil, in which case it is not
+// advertised.
+func NewPiggybackingClient(dialer Dialer, caller Caller, listenAddr net.Addr, membership membership.Server, logger logging.Logger) gossip.Client {
+	return newClient(dialer, caller, listenAddr, membership, logger)
+}
+
+// NewMembershipClient returns an implementation of the `membership.Client`
+// interface that uses gRPC to invoke RPCs.
+func NewMembershipClient(dialer Dialer, caller Caller, logger logging.Logger) membership.Client {
+	return newClient(dialer, caller, nil, nil, logger)
+}
+
+// NewViewClient returns an implementation of the `view.Client` interface that
 // uses gRPC to invoke RPCs.
-func NewClient(dialer Dialer, caller Caller) gossip.Client {
-	return &client{dialer, caller}
+func NewViewClient(dialer Dialer, caller Caller, logger logging.Logger) view.Client {
+	return newClient(dialer, caller, nil, nil, logger)
+}
+
+func newClient(dialer Dialer, caller Caller, listenAddr net.Addr, membership membership.Server, logger logging.Logger) *client {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	return &client{dialer, caller, listenAddr, membership, logger}
 }
 
 // Send a `message` to the `to` address. A `context.Context` can be used to
@@ -41,34 +109,257 @@ func NewClient(dialer Dialer, caller Caller) gossip.Client {
 func (client *client) Send(ctx context.Context, to net.Addr, message gossip.Message) error {
 	conn, err := client.Dial(ctx, to)
 	if err != nil {
+		return client.logError("cannot dial", to, err)
+	}
+	defer conn.Close()
+
+	request := marshalMessage(message)
+	request.Updates = client.piggyback()
+	if client.listenAddr != nil {
+		ctx = metadata.AppendToOutgoingContext(ctx, ListenAddrKey, client.listenAddr.String())
+	}
+
+	var response *SendResponse
+	if err := client.Call(ctx, func() error {
+		response, err = NewBabbleClient(conn).Send(ctx, request)
 		return err
+	}); err != nil {
+		if responded(err) {
+			err = gossip.RejectedError{Err: err}
+		}
+		return client.logError("cannot send message", to, err, logging.Key(message.Key), logging.Nonce(message.Nonce))
+	}
+	client.apply(response.Updates)
+	return nil
+}
+
+// Sync sends the `digests` of the subtree at the `path` to the `to` address,
+// and returns the Messages and Digests that are returned in response. A
+// `context.Context` can be used to cancel or expire the request.
+func (client *client) Sync(ctx context.Context, to net.Addr, path []byte, digests []gossip.Digest) ([]gossip.Message, []gossip.Digest, error) {
+	conn, err := client.Dial(ctx, to)
+	if err != nil {
+		return nil, nil, client.logError("cannot dial", to, err)
 	}
 	defer conn.Close()
 
-	request := &SendRequest{
-		Nonce:     message.Nonce,
-		Key:       message.Key,
-		Value:     message.Value,
-		Signature: message.Signature,
+	request := &SyncRequest{
+		Digests: marshalDigests(digests),
+		Path:    path,
+		Updates: client.piggyback(),
 	}
 
-	return client.Call(ctx, func() error {
-		_, err = NewBabbleClient(conn).Send(ctx, request)
+	var response *SyncResponse
+	if err := client.Call(ctx, func() error {
+		response, err = NewBabbleClient(conn).Sync(ctx, request)
 		return err
+	}); err != nil {
+		return nil, nil, client.logError("cannot call sync", to, err)
+	}
+	client.apply(response.Updates)
+
+	messages := make([]gossip.Message, len(response.Messages))
+	for i := range response.Messages {
+		messages[i] = unmarshalMessage(response.Messages[i])
+	}
+	return messages, unmarshalDigests(response.Wanted), nil
+}
+
+// Hashes requests the hashes of the children of the node at the `path` in the
+// Merkle tree of the `to` address. A `context.Context` can be used to cancel or
+// expire the request.
+func (client *client) Hashes(ctx context.Context, to net.Addr, path []byte) ([][]byte, error) {
+	conn, err := client.Dial(ctx, to)
+	if err != nil {
+		return nil, client.logError("cannot dial", to, err)
+	}
+	defer conn.Close()
+
+	request := &HashesRequest{
+		Path: path,
+	}
+
+	var response *HashesResponse
+	if err := client.Call(ctx, func() error {
+		response, err = NewBabbleClient(conn).Hashes(ctx, request)
+		return err
+	}); err != nil {
+		return nil, client.logError("cannot call hashes", to, err)
+	}
+
+	// Empty hashes are decoded as empty slices, but the empty hash of a
+	// `gossip.Tree` is nil
+	hashes := response.Hashes
+	for i := range hashes {
+		if len(hashes[i]) == 0 {
+			hashes[i] = nil
+		}
+	}
+	return hashes, nil
+}
+
+// Peers requests a random sample of at most n addresses that are known to the
+// `to` address. A `context.Context` can be used to cancel or expire the
+// request.
+func (client *client) Peers(ctx context.Context, to net.Addr, n int) ([]net.Addr, error) {
+	conn, err := client.Dial(ctx, to)
+	if err != nil {
+		return nil, client.logError("cannot dial", to, err)
+	}
+	defer conn.Close()
+
+	request := &PeersRequest{
+		Count: uint32(n),
+	}
+
+	var response *PeersResponse
+	if err := client.Call(ctx, func() error {
+		response, err = NewBabbleClient(conn).Peers(ctx, request)
+		return err
+	}); err != nil {
+		return nil, client.logError("cannot call peers", to, err)
+	}
+
+	return unmarshalAddrs(response.Addrs), nil
+}
+
+// Ping the `to` address, piggybacking the `updates`. It returns the Updates
+// that are piggybacked onto the response. A `context.Context` can be used to
+// cancel or expire the request.
+func (client *client) Ping(ctx context.Context, to net.Addr, updates []membership.Update) ([]membership.Update, error) {
+	return client.ping(ctx, to, &PingRequest{
+		Updates: marshalUpdates(updates),
+	})
+}
+
+// PingIndirect asks the `via` address to ping the `target` address,
+// piggybacking the `updates`. It returns the Updates that are piggybacked onto
+// the response. A `context.Context` can be used to cancel or expire the
+// request.
+func (client *client) PingIndirect(ctx context.Context, via, target net.Addr, updates []membership.Update) ([]membership.Update, error) {
+	return client.ping(ctx, via, &PingRequest{
+		Target:  marshalAddr(target),
+		Updates: marshalUpdates(updates),
 	})
 }
 
+func (client *client) ping(ctx context.Context, to net.Addr, request *PingRequest) ([]membership.Update, error) {
+	conn, err := client.Dial(ctx, to)
+	if err != nil {
+		return nil, client.logError("cannot dial", to, err)
+	}
+	defer conn.Close()
+
+	var response *PingResponse
+	if err := client.Call(ctx, func() error {
+		response, err = NewBabbleClient(conn).Ping(ctx, request)
+		return err
+	}); err != nil {
+		return nil, client.logError("cannot call ping", to, err)
+	}
+	return unmarshalUpdates(response.Updates), nil
+}
+
+// Shuffle sends the `addrs` to the `to` address, and returns the addresses that
+// it responds with. A `context.Context` can be used to cancel or expire the
+// request.
+func (client *client) Shuffle(ctx context.Context, to net.Addr, addrs []net.Addr) ([]net.Addr, error) {
+	conn, err := client.Dial(ctx, to)
+	if err != nil {
+		return nil, client.logError("cannot dial", to, err)
+	}
+	defer conn.Close()
+
+	request := &ShuffleRequest{
+		Addrs: marshalAddrs(addrs),
+	}
+
+	var response *ShuffleResponse
+	if err := client.Call(ctx, func() error {
+		response, err = NewBabbleClient(conn).Shuffle(ctx, request)
+		return err
+	}); err != nil {
+		return nil, client.logError("cannot call shuffle", to, err)
+	}
+	return unmarshalAddrs(response.Addrs), nil
+}
+
+// piggyback returns the Updates of the `membership.Server` of the client, if it
+// has one, to be piggybacked onto a request.
+func (client *client) piggyback() []*Member {
+	if client.membership == nil {
+		return nil
+	}
+	return marshalUpdates(client.membership.Piggyback())
+}
+
+// apply the Updates that were piggybacked onto a response to the
+// `membership.Server` of the client, if it has one.
+func (client *client) apply(updates []*Member) {
+	if client.membership == nil || len(updates) == 0 {
+		return
+	}
+	client.membership.Apply(unmarshalUpdates(updates))
+}
+
+// responded returns true if the error of an RPC was returned by the remote
+// peer, instead of by the transport, because the remote peer could not be
+// reached in time.
+func responded(err error) bool {
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return false
+	default:
+		return true
+	}
+}
+
+// logError logs a failed RPC to a remote peer, unless the error is nil. It
+// returns the error, so that it can wrap return values. Failed RPCs are
+// expected when peers go offline, and are returned to the caller, so they are
+// only logged at the debug level.
+func (client *client) logError(msg string, to net.Addr, err error, fields ...logging.Field) error {
+	if err != nil {
+		client.logger.Debug(msg, append([]logging.Field{logging.Peer(to)}, append(fields, logging.Err(err))...)...)
+	}
+	return err
+}
+
 // Service implements a gRPC Service that accepts RPCs from clients. It
 // delegates requests to a `gossip.Server` after enforcing rate limits.
 type Service struct {
-	server gossip.Server
+	server     gossip.Server
+	membership membership.Server
+	view       view.Server
+	logger     logging.Logger
+
+	mu         *sync.Mutex
+	grpcServer *grpc.Server
 }
 
-// NewService returns a Service that delegates requests to the `server` and uses
-// `rate` to enforce rate limits of all RPCs.
-func NewService(server gossip.Server) Service {
+// NewService returns a Service that delegates requests to the `server`,
+// delegates pings to the `membership`, and delegates shuffles to the `view`.
+// Updates that are piggybacked onto sends and syncs are also exchanged with the
+// `membership`.
+// The `membership` can be nil, in which case pings return ErrNoMembership. The
+// `view` can be nil, in which case shuffles return ErrNoView. Failed RPCs are
+// logged by the `logger`, which can be nil, in which case the default
+// `logging.Logger` is used.
+func NewService(server gossip.Server, membership membership.Server, view view.Server, logger logging.Logger) Service {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
 	return Service{
-		server: server,
+		server:     server,
+		membership: membership,
+		view:       view,
+		logger:     logger,
+
+		mu: new(sync.Mutex),
 	}
 }
 
@@ -77,14 +368,325 @@ func (service *Service) Register(server *grpc.Server) {
 	RegisterBabbleServer(server, service)
 }
 
-// Send implements the respective gRPC call.
+// Start serving the Service from its own `grpc.Server` on the listener, until
+// the Service is stopped. It returns ErrAlreadyStarted if the Service is
+// already serving.
+func (service *Service) Start(lis net.Listener, opts ...grpc.ServerOption) error {
+	service.mu.Lock()
+	defer service.mu.Unlock()
+
+	if service.grpcServer != nil {
+		return ErrAlreadyStarted
+	}
+	service.grpcServer = grpc.NewServer(opts...)
+	service.Register(service.grpcServer)
+
+	go func(server *grpc.Server) {
+		if err := server.Serve(lis); err != nil {
+			service.logger.Error("cannot serve", logging.Any("addr", lis.Addr().String()), logging.Err(err))
+		}
+	}(service.grpcServer)
+	return nil
+}
+
+// Stop the `grpc.Server` that was started by Start. It stops accepting new
+// RPCs, and waits for in-flight RPCs to finish until the context is done, in
+// which case they are cancelled and the error of the context is returned.
+func (service *Service) Stop(ctx context.Context) error {
+	service.mu.Lock()
+	server := service.grpcServer
+	service.grpcServer = nil
+	service.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		server.GracefulStop()
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		server.Stop()
+		<-stopped
+		service.logger.Warn("cannot stop gracefully", logging.Err(ctx.Err()))
+		return ctx.Err()
+	}
+}
+
+// Send implements the respective gRPC call. When the client advertises its
+// listen address, it is passed to the `gossip.Server` as the sender of the
+// Message.
 func (service *Service) Send(ctx context.Context, request *SendRequest) (*SendResponse, error) {
-	message := gossip.Message{
+	if sender, ok := senderFromContext(ctx); ok {
+		ctx = gossip.WithSender(ctx, sender)
+	}
+	service.apply(request.Updates)
+
+	message := unmarshalMessage(request)
+	if err := service.server.Receive(ctx, message); err != nil {
+		return nil, service.logError(ctx, "cannot receive message", err, logging.Key(message.Key), logging.Nonce(message.Nonce))
+	}
+	return &SendResponse{Updates: service.piggyback()}, nil
+}
+
+// Sync implements the respective gRPC call.
+func (service *Service) Sync(ctx context.Context, request *SyncRequest) (*SyncResponse, error) {
+	service.apply(request.Updates)
+
+	messages, wanted, err := service.server.Sync(ctx, request.Path, unmarshalDigests(request.Digests))
+	if err != nil {
+		return nil, service.logError(ctx, "cannot sync", err)
+	}
+
+	response := &SyncResponse{
+		Messages: make([]*SendRequest, len(messages)),
+		Wanted:   marshalDigests(wanted),
+		Updates:  service.piggyback(),
+	}
+	for i := range messages {
+		response.Messages[i] = marshalMessage(messages[i])
+	}
+	return response, nil
+}
+
+// Hashes implements the respective gRPC call.
+func (service *Service) Hashes(ctx context.Context, request *HashesRequest) (*HashesResponse, error) {
+	hashes, err := service.server.Hashes(ctx, request.Path)
+	if err != nil {
+		return nil, service.logError(ctx, "cannot get hashes", err)
+	}
+	return &HashesResponse{Hashes: hashes}, nil
+}
+
+// Peers implements the respective gRPC call.
+func (service *Service) Peers(ctx context.Context, request *PeersRequest) (*PeersResponse, error) {
+	n := gossip.MaxPeers
+	if request.Count < uint32(n) {
+		n = int(request.Count)
+	}
+	addrs, err := service.server.Peers(ctx, n)
+	if err != nil {
+		return nil, service.logError(ctx, "cannot get peers", err)
+	}
+
+	return &PeersResponse{Addrs: marshalAddrs(addrs)}, nil
+}
+
+// Ping implements the respective gRPC call. A request with a target is an
+// indirect ping.
+func (service *Service) Ping(ctx context.Context, request *PingRequest) (*PingResponse, error) {
+	if service.membership == nil {
+		return nil, service.logError(ctx, "cannot ping", ErrNoMembership)
+	}
+
+	var updates []membership.Update
+	var err error
+	if request.Target != nil {
+		updates, err = service.membership.PingIndirect(ctx, unmarshalAddr(request.Target), unmarshalUpdates(request.Updates))
+	} else {
+		updates, err = service.membership.Ping(ctx, unmarshalUpdates(request.Updates))
+	}
+	if err != nil {
+		return nil, service.logError(ctx, "cannot ping", err)
+	}
+	return &PingResponse{Updates: marshalUpdates(updates)}, nil
+}
+
+// Shuffle implements the respective gRPC call.
+func (service *Service) Shuffle(ctx context.Context, request *ShuffleRequest) (*ShuffleResponse, error) {
+	if service.view == nil {
+		return nil, service.logError(ctx, "cannot shuffle", ErrNoView)
+	}
+
+	addrs, err := service.view.Shuffle(ctx, unmarshalAddrs(request.Addrs))
+	if err != nil {
+		return nil, service.logError(ctx, "cannot shuffle", err)
+	}
+	return &ShuffleResponse{Addrs: marshalAddrs(addrs)}, nil
+}
+
+// piggyback returns the Updates of the `membership.Server` of the Service, if
+// it has one, to be piggybacked onto a response.
+func (service *Service) piggyback() []*Member {
+	if service.membership == nil {
+		return nil
+	}
+	return marshalUpdates(service.membership.Piggyback())
+}
+
+// apply the Updates that were piggybacked onto a request to the
+// `membership.Server` of the Service, if it has one.
+func (service *Service) apply(updates []*Member) {
+	if service.membership == nil || len(updates) == 0 {
+		return
+	}
+	service.membership.Apply(unmarshalUpdates(updates))
+}
+
+// logError logs a failed RPC from a remote peer, unless the error is nil. It
+// returns the error, so that it can wrap return values. The servers that the
+// Service delegates to log their own failures, so failed RPCs are only logged
+// at the debug level.
+func (service *Service) logError(ctx context.Context, msg string, err error, fields ...logging.Field) error {
+	if err == nil {
+		return nil
+	}
+	var from net.Addr
+	if p, ok := peer.FromContext(ctx); ok {
+		from = p.Addr
+	}
+	service.logger.Debug(msg, append([]logging.Field{logging.Peer(from)}, append(fields, logging.Err(err))...)...)
+	return err
+}
+
+// senderFromContext returns the address at which the client of a gRPC call can
+// be reached. It combines the host of the connection with the port of the
+// advertised listen address, so that a client cannot advertise the address of
+// another host.
+func senderFromContext(ctx context.Context) (net.Addr, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return nil, false
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md[ListenAddrKey]) == 0 {
+		return nil, false
+	}
+	_, port, err := net.SplitHostPort(md[ListenAddrKey][0])
+	if err != nil {
+		return nil, false
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return nil, false
+	}
+	return peerAddr{
+		network: p.Addr.Network(),
+		value:   net.JoinHostPort(host, port),
+	}, true
+}
+
+// peerAddr is a `net.Addr` that was received from a remote peer.
+type peerAddr struct {
+	network string
+	value   string
+}
+
+// Network implements the `net.Addr` interface.
+func (addr peerAddr) Network() string {
+	return addr.network
+}
+
+// String implements the `net.Addr` interface.
+func (addr peerAddr) String() string {
+	return addr.value
+}
+
+func marshalMessage(message gossip.Message) *SendRequest {
+	return &SendRequest{
+		Nonce:     message.Nonce,
+		Key:       message.Key,
+		Value:     message.Value,
+		Scheme:    uint32(message.Scheme),
+		Signature: message.Signature,
+	}
+}
+
+func unmarshalMessage(request *SendRequest) gossip.Message {
+	return gossip.Message{
 		Nonce:     request.Nonce,
 		Key:       request.Key,
 		Value:     request.Value,
+		Scheme:    gossip.Scheme(request.Scheme),
 		Signature: request.Signature,
 	}
+}
 
-	return &SendResponse{}, service.server.Receive(ctx, message)
+func marshalDigests(digests []gossip.Digest) []*Digest {
+	ret := make([]*Digest, len(digests))
+	for i := range digests {
+		ret[i] = &Digest{
+			Key:   digests[i].Key,
+			Nonce: digests[i].Nonce,
+			Hash:  digests[i].Hash,
+		}
+	}
+	return ret
+}
+
+func unmarshalDigests(digests []*Digest) []gossip.Digest {
+	ret := make([]gossip.Digest, len(digests))
+	for i := range digests {
+		ret[i] = gossip.Digest{
+			Key:   digests[i].Key,
+			Nonce: digests[i].Nonce,
+			Hash:  digests[i].Hash,
+		}
+	}
+	return ret
+}
+
+func marshalAddr(addr net.Addr) *Addr {
+	return &Addr{
+		Network: addr.Network(),
+		Value:   addr.String(),
+	}
+}
+
+func unmarshalAddr(addr *Addr) net.Addr {
+	return peerAddr{
+		network: addr.Network,
+		value:   addr.Value,
+	}
+}
+
+func marshalAddrs(addrs []net.Addr) []*Addr {
+	ret := make([]*Addr, len(addrs))
+	for i := range addrs {
+		ret[i] = marshalAddr(addrs[i])
+	}
+	return ret
+}
+
+func unmarshalAddrs(addrs []*Addr) []net.Addr {
+	ret := make([]net.Addr, 0, len(addrs))
+	for i := range addrs {
+		if addrs[i] == nil {
+			continue
+		}
+		ret = append(ret, unmarshalAddr(addrs[i]))
+	}
+	return ret
+}
+
+func marshalUpdates(updates []membership.Update) []*Member {
+	ret := make([]*Member, len(updates))
+	for i := range updates {
+		ret[i] = &Member{
+			Addr:        marshalAddr(updates[i].Addr),
+			State:       uint32(updates[i].State),
+			Incarnation: updates[i].Incarnation,
+		}
+	}
+	return ret
+}
+
+func unmarshalUpdates(members []*Member) []membership.Update {
+	ret := make([]membership.Update, 0, len(members))
+	for i := range members {
+		if members[i].Addr == nil {
+			continue
+		}
+		ret = append(ret, membership.Update{
+			Addr:        unmarshalAddr(members[i].Addr),
+			State:       membership.State(members[i].State),
+			Incarnation: members[i].Incarnation,
+		})
+	}
+	return ret
 }
@@ -10,6 +10,19 @@ It is generated from these files:
 It has these top-level messages:
 	SendRequest
 	SendResponse
+	Digest
+	SyncRequest
+	SyncResponse
+	HashesRequest
+	HashesResponse
+	Addr
+	PeersRequest
+	PeersResponse
+	Member
+	PingRequest
+	PingResponse
+	ShuffleRequest
+	ShuffleResponse
 */
 package rpc
 
@@ -34,10 +47,12 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type SendRequest struct {
-	Nonce     uint64 `protobuf:"varint,1,opt,name=nonce" json:"nonce,omitempty"`
-	Key       []byte `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
-	Value     []byte `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
-	Signature []byte `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	Nonce     uint64    `protobuf:"varint,1,opt,name=nonce" json:"nonce,omitempty"`
+	Key       []byte    `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value     []byte    `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Signature []byte    `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	Scheme    uint32    `protobuf:"varint,5,opt,name=scheme" json:"scheme,omitempty"`
+	Updates   []*Member `protobuf:"bytes,6,rep,name=updates" json:"updates,omitempty"`
 }
 
 func (m *SendRequest) Reset()                    { *m = SendRequest{} }
@@ -73,7 +88,22 @@ func (m *SendRequest) GetSignature() []byte {
 	return nil
 }
 
+func (m *SendRequest) GetScheme() uint32 {
+	if m != nil {
+		return m.Scheme
+	}
+	return 0
+}
+
+func (m *SendRequest) GetUpdates() []*Member {
+	if m != nil {
+		return m.Updates
+	}
+	return nil
+}
+
 type SendResponse struct {
+	Updates []*Member `protobuf:"bytes,1,rep,name=updates" json:"updates,omitempty"`
 }
 
 func (m *SendResponse) Reset()                    { *m = SendResponse{} }
@@ -81,9 +111,317 @@ func (m *SendResponse) String() string            { return proto.CompactTextStri
 func (*SendResponse) ProtoMessage()               {}
 func (*SendResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
 
+func (m *SendResponse) GetUpdates() []*Member {
+	if m != nil {
+		return m.Updates
+	}
+	return nil
+}
+
+type Digest struct {
+	Key   []byte `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Nonce uint64 `protobuf:"varint,2,opt,name=nonce" json:"nonce,omitempty"`
+	Hash  []byte `protobuf:"bytes,3,opt,name=hash,proto3" json:"hash,omitempty"`
+}
+
+func (m *Digest) Reset()                    { *m = Digest{} }
+func (m *Digest) String() string            { return proto.CompactTextString(m) }
+func (*Digest) ProtoMessage()               {}
+func (*Digest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *Digest) GetKey() []byte {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+func (m *Digest) GetNonce() uint64 {
+	if m != nil {
+		return m.Nonce
+	}
+	return 0
+}
+
+func (m *Digest) GetHash() []byte {
+	if m != nil {
+		return m.Hash
+	}
+	return nil
+}
+
+type SyncRequest struct {
+	Digests []*Digest `protobuf:"bytes,1,rep,name=digests" json:"digests,omitempty"`
+	Path    []byte    `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Updates []*Member `protobuf:"bytes,3,rep,name=updates" json:"updates,omitempty"`
+}
+
+func (m *SyncRequest) Reset()                    { *m = SyncRequest{} }
+func (m *SyncRequest) String() string            { return proto.CompactTextString(m) }
+func (*SyncRequest) ProtoMessage()               {}
+func (*SyncRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *SyncRequest) GetDigests() []*Digest {
+	if m != nil {
+		return m.Digests
+	}
+	return nil
+}
+
+func (m *SyncRequest) GetPath() []byte {
+	if m != nil {
+		return m.Path
+	}
+	return nil
+}
+
+func (m *SyncRequest) GetUpdates() []*Member {
+	if m != nil {
+		return m.Updates
+	}
+	return nil
+}
+
+type SyncResponse struct {
+	Messages []*SendRequest `protobuf:"bytes,1,rep,name=messages" json:"messages,omitempty"`
+	Wanted   []*Digest      `protobuf:"bytes,2,rep,name=wanted" json:"wanted,omitempty"`
+	Updates  []*Member      `protobuf:"bytes,3,rep,name=updates" json:"updates,omitempty"`
+}
+
+func (m *SyncResponse) Reset()                    { *m = SyncResponse{} }
+func (m *SyncResponse) String() string            { return proto.CompactTextString(m) }
+func (*SyncResponse) ProtoMessage()               {}
+func (*SyncResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+func (m *SyncResponse) GetMessages() []*SendRequest {
+	if m != nil {
+		return m.Messages
+	}
+	return nil
+}
+
+func (m *SyncResponse) GetWanted() []*Digest {
+	if m != nil {
+		return m.Wanted
+	}
+	return nil
+}
+
+func (m *SyncResponse) GetUpdates() []*Member {
+	if m != nil {
+		return m.Updates
+	}
+	return nil
+}
+
+type HashesRequest struct {
+	Path []byte `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+}
+
+func (m *HashesRequest) Reset()                    { *m = HashesRequest{} }
+func (m *HashesRequest) String() string            { return proto.CompactTextString(m) }
+func (*HashesRequest) ProtoMessage()               {}
+func (*HashesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+func (m *HashesRequest) GetPath() []byte {
+	if m != nil {
+		return m.Path
+	}
+	return nil
+}
+
+type HashesResponse struct {
+	Hashes [][]byte `protobuf:"bytes,1,rep,name=hashes,proto3" json:"hashes,omitempty"`
+}
+
+func (m *HashesResponse) Reset()                    { *m = HashesResponse{} }
+func (m *HashesResponse) String() string            { return proto.CompactTextString(m) }
+func (*HashesResponse) ProtoMessage()               {}
+func (*HashesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+
+func (m *HashesResponse) GetHashes() [][]byte {
+	if m != nil {
+		return m.Hashes
+	}
+	return nil
+}
+
+type Addr struct {
+	Network string `protobuf:"bytes,1,opt,name=network" json:"network,omitempty"`
+	Value   string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (m *Addr) Reset()                    { *m = Addr{} }
+func (m *Addr) String() string            { return proto.CompactTextString(m) }
+func (*Addr) ProtoMessage()               {}
+func (*Addr) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *Addr) GetNetwork() string {
+	if m != nil {
+		return m.Network
+	}
+	return ""
+}
+
+func (m *Addr) GetValue() string {
+	if m != nil {
+		return m.Value
+	}
+	return ""
+}
+
+type PeersRequest struct {
+	Count uint32 `protobuf:"varint,1,opt,name=count" json:"count,omitempty"`
+}
+
+func (m *PeersRequest) Reset()                    { *m = PeersRequest{} }
+func (m *PeersRequest) String() string            { return proto.CompactTextString(m) }
+func (*PeersRequest) ProtoMessage()               {}
+func (*PeersRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *PeersRequest) GetCount() uint32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+type PeersResponse struct {
+	Addrs []*Addr `protobuf:"bytes,1,rep,name=addrs" json:"addrs,omitempty"`
+}
+
+func (m *PeersResponse) Reset()                    { *m = PeersResponse{} }
+func (m *PeersResponse) String() string            { return proto.CompactTextString(m) }
+func (*PeersResponse) ProtoMessage()               {}
+func (*PeersResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+func (m *PeersResponse) GetAddrs() []*Addr {
+	if m != nil {
+		return m.Addrs
+	}
+	return nil
+}
+
+type Member struct {
+	Addr        *Addr  `protobuf:"bytes,1,opt,name=addr" json:"addr,omitempty"`
+	State       uint32 `protobuf:"varint,2,opt,name=state" json:"state,omitempty"`
+	Incarnation uint64 `protobuf:"varint,3,opt,name=incarnation" json:"incarnation,omitempty"`
+}
+
+func (m *Member) Reset()                    { *m = Member{} }
+func (m *Member) String() string            { return proto.CompactTextString(m) }
+func (*Member) ProtoMessage()               {}
+func (*Member) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+func (m *Member) GetAddr() *Addr {
+	if m != nil {
+		return m.Addr
+	}
+	return nil
+}
+
+func (m *Member) GetState() uint32 {
+	if m != nil {
+		return m.State
+	}
+	return 0
+}
+
+func (m *Member) GetIncarnation() uint64 {
+	if m != nil {
+		return m.Incarnation
+	}
+	return 0
+}
+
+type PingRequest struct {
+	Target  *Addr     `protobuf:"bytes,1,opt,name=target" json:"target,omitempty"`
+	Updates []*Member `protobuf:"bytes,2,rep,name=updates" json:"updates,omitempty"`
+}
+
+func (m *PingRequest) Reset()                    { *m = PingRequest{} }
+func (m *PingRequest) String() string            { return proto.CompactTextString(m) }
+func (*PingRequest) ProtoMessage()               {}
+func (*PingRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+func (m *PingRequest) GetTarget() *Addr {
+	if m != nil {
+		return m.Target
+	}
+	return nil
+}
+
+func (m *PingRequest) GetUpdates() []*Member {
+	if m != nil {
+		return m.Updates
+	}
+	return nil
+}
+
+type PingResponse struct {
+	Updates []*Member `protobuf:"bytes,1,rep,name=updates" json:"updates,omitempty"`
+}
+
+func (m *PingResponse) Reset()                    { *m = PingResponse{} }
+func (m *PingResponse) String() string            { return proto.CompactTextString(m) }
+func (*PingResponse) ProtoMessage()               {}
+func (*PingResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *PingResponse) GetUpdates() []*Member {
+	if m != nil {
+		return m.Updates
+	}
+	return nil
+}
+
+type ShuffleRequest struct {
+	Addrs []*Addr `protobuf:"bytes,1,rep,name=addrs" json:"addrs,omitempty"`
+}
+
+func (m *ShuffleRequest) Reset()                    { *m = ShuffleRequest{} }
+func (m *ShuffleRequest) String() string            { return proto.CompactTextString(m) }
+func (*ShuffleRequest) ProtoMessage()               {}
+func (*ShuffleRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *ShuffleRequest) GetAddrs() []*Addr {
+	if m != nil {
+		return m.Addrs
+	}
+	return nil
+}
+
+type ShuffleResponse struct {
+	Addrs []*Addr `protobuf:"bytes,1,rep,name=addrs" json:"addrs,omitempty"`
+}
+
+func (m *ShuffleResponse) Reset()                    { *m = ShuffleResponse{} }
+func (m *ShuffleResponse) String() string            { return proto.CompactTextString(m) }
+func (*ShuffleResponse) ProtoMessage()               {}
+func (*ShuffleResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *ShuffleResponse) GetAddrs() []*Addr {
+	if m != nil {
+		return m.Addrs
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*SendRequest)(nil), "rpc.SendRequest")
 	proto.RegisterType((*SendResponse)(nil), "rpc.SendResponse")
+	proto.RegisterType((*Digest)(nil), "rpc.Digest")
+	proto.RegisterType((*SyncRequest)(nil), "rpc.SyncRequest")
+	proto.RegisterType((*SyncResponse)(nil), "rpc.SyncResponse")
+	proto.RegisterType((*HashesRequest)(nil), "rpc.HashesRequest")
+	proto.RegisterType((*HashesResponse)(nil), "rpc.HashesResponse")
+	proto.RegisterType((*Addr)(nil), "rpc.Addr")
+	proto.RegisterType((*PeersRequest)(nil), "rpc.PeersRequest")
+	proto.RegisterType((*PeersResponse)(nil), "rpc.PeersResponse")
+	proto.RegisterType((*Member)(nil), "rpc.Member")
+	proto.RegisterType((*PingRequest)(nil), "rpc.PingRequest")
+	proto.RegisterType((*PingResponse)(nil), "rpc.PingResponse")
+	proto.RegisterType((*ShuffleRequest)(nil), "rpc.ShuffleRequest")
+	proto.RegisterType((*ShuffleResponse)(nil), "rpc.ShuffleResponse")
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -98,6 +436,11 @@ const _ = grpc.SupportPackageIsVersion4
 
 type BabbleClient interface {
 	Send(ctx context.Context, in *SendRequest, opts ...grpc.CallOption) (*SendResponse, error)
+	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*SyncResponse, error)
+	Hashes(ctx context.Context, in *HashesRequest, opts ...grpc.CallOption) (*HashesResponse, error)
+	Peers(ctx context.Context, in *PeersRequest, opts ...grpc.CallOption) (*PeersResponse, error)
+	Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error)
+	Shuffle(ctx context.Context, in *ShuffleRequest, opts ...grpc.CallOption) (*ShuffleResponse, error)
 }
 
 type babbleClient struct {
@@ -117,10 +460,60 @@ func (c *babbleClient) Send(ctx context.Context, in *SendRequest, opts ...grpc.C
 	return out, nil
 }
 
+func (c *babbleClient) Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (*SyncResponse, error) {
+	out := new(SyncResponse)
+	err := grpc.Invoke(ctx, "/rpc.Babble/Sync", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *babbleClient) Hashes(ctx context.Context, in *HashesRequest, opts ...grpc.CallOption) (*HashesResponse, error) {
+	out := new(HashesResponse)
+	err := grpc.Invoke(ctx, "/rpc.Babble/Hashes", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *babbleClient) Peers(ctx context.Context, in *PeersRequest, opts ...grpc.CallOption) (*PeersResponse, error) {
+	out := new(PeersResponse)
+	err := grpc.Invoke(ctx, "/rpc.Babble/Peers", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *babbleClient) Ping(ctx context.Context, in *PingRequest, opts ...grpc.CallOption) (*PingResponse, error) {
+	out := new(PingResponse)
+	err := grpc.Invoke(ctx, "/rpc.Babble/Ping", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *babbleClient) Shuffle(ctx context.Context, in *ShuffleRequest, opts ...grpc.CallOption) (*ShuffleResponse, error) {
+	out := new(ShuffleResponse)
+	err := grpc.Invoke(ctx, "/rpc.Babble/Shuffle", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // Server API for Babble service
 
 type BabbleServer interface {
 	Send(context.Context, *SendRequest) (*SendResponse, error)
+	Sync(context.Context, *SyncRequest) (*SyncResponse, error)
+	Hashes(context.Context, *HashesRequest) (*HashesResponse, error)
+	Peers(context.Context, *PeersRequest) (*PeersResponse, error)
+	Ping(context.Context, *PingRequest) (*PingResponse, error)
+	Shuffle(context.Context, *ShuffleRequest) (*ShuffleResponse, error)
 }
 
 func RegisterBabbleServer(s *grpc.Server, srv BabbleServer) {
@@ -145,6 +538,96 @@ func _Babble_Send_Handler(srv interface{}, ctx context.Context, dec func(interfa
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Babble_Sync_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SyncRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BabbleServer).Sync(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.Babble/Sync",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BabbleServer).Sync(ctx, req.(*SyncRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Babble_Hashes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HashesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BabbleServer).Hashes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.Babble/Hashes",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BabbleServer).Hashes(ctx, req.(*HashesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Babble_Peers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PeersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BabbleServer).Peers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.Babble/Peers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BabbleServer).Peers(ctx, req.(*PeersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Babble_Ping_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BabbleServer).Ping(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.Babble/Ping",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BabbleServer).Ping(ctx, req.(*PingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Babble_Shuffle_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShuffleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BabbleServer).Shuffle(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.Babble/Shuffle",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BabbleServer).Shuffle(ctx, req.(*ShuffleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Babble_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "rpc.Babble",
 	HandlerType: (*BabbleServer)(nil),
@@ -153,6 +636,26 @@ var _Babble_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Send",
 			Handler:    _Babble_Send_Handler,
 		},
+		{
+			MethodName: "Sync",
+			Handler:    _Babble_Sync_Handler,
+		},
+		{
+			MethodName: "Hashes",
+			Handler:    _Babble_Hashes_Handler,
+		},
+		{
+			MethodName: "Peers",
+			Handler:    _Babble_Peers_Handler,
+		},
+		{
+			MethodName: "Ping",
+			Handler:    _Babble_Ping_Handler,
+		},
+		{
+			MethodName: "Shuffle",
+			Handler:    _Babble_Shuffle_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "rpc.proto",
@@ -161,16 +664,41 @@ var _Babble_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("rpc.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 165 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x2c, 0x2a, 0x48, 0xd6,
-	0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0x62, 0x2e, 0x2a, 0x48, 0x56, 0xca, 0xe4, 0xe2, 0x0e, 0x4e,
-	0xcd, 0x4b, 0x09, 0x4a, 0x2d, 0x2c, 0x4d, 0x2d, 0x2e, 0x11, 0x12, 0xe1, 0x62, 0xcd, 0xcb, 0xcf,
-	0x4b, 0x4e, 0x95, 0x60, 0x54, 0x60, 0xd4, 0x60, 0x09, 0x82, 0x70, 0x84, 0x04, 0xb8, 0x98, 0xb3,
-	0x53, 0x2b, 0x25, 0x98, 0x14, 0x18, 0x35, 0x78, 0x82, 0x40, 0x4c, 0x90, 0xba, 0xb2, 0xc4, 0x9c,
-	0xd2, 0x54, 0x09, 0x66, 0xb0, 0x18, 0x84, 0x23, 0x24, 0xc3, 0xc5, 0x59, 0x9c, 0x99, 0x9e, 0x97,
-	0x58, 0x52, 0x5a, 0x94, 0x2a, 0xc1, 0x02, 0x96, 0x41, 0x08, 0x28, 0xf1, 0x71, 0xf1, 0x40, 0xac,
-	0x2a, 0x2e, 0xc8, 0xcf, 0x2b, 0x4e, 0x35, 0x32, 0xe6, 0x62, 0x89, 0xc8, 0xaf, 0xc8, 0x17, 0xd2,
-	0xe6, 0x62, 0x01, 0x89, 0x0b, 0x09, 0xe8, 0x81, 0xdc, 0x86, 0xe4, 0x1a, 0x29, 0x41, 0x24, 0x11,
-	0x88, 0xa6, 0x24, 0x36, 0xb0, 0xdb, 0x8d, 0x01, 0x01, 0x00, 0x00, 0xff, 0xff, 0xf1, 0x0f, 0x57,
-	0x3e, 0xc8, 0x00, 0x00, 0x00,
+	// 575 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xdd, 0x6e, 0xd3, 0x30,
+	0x14, 0x56, 0xd2, 0x34, 0xa5, 0x27, 0xed, 0x58, 0xcd, 0x34, 0x45, 0x11, 0x88, 0x92, 0x31, 0xa9,
+	0x12, 0xa8, 0x62, 0xe5, 0xe7, 0x1e, 0xb4, 0x0b, 0x6e, 0x90, 0x26, 0xf7, 0x82, 0x4b, 0xe4, 0x26,
+	0x5e, 0x53, 0xad, 0x75, 0x42, 0xec, 0x30, 0xf5, 0x0d, 0x78, 0x94, 0x3d, 0x26, 0xf2, 0x5f, 0xeb,
+	0xa8, 0x52, 0xd9, 0xee, 0x7c, 0xbe, 0xf3, 0xf7, 0x7d, 0xc7, 0xc7, 0x86, 0x7e, 0x5d, 0x65, 0xd3,
+	0xaa, 0x2e, 0x45, 0x89, 0x3a, 0x75, 0x95, 0xa5, 0x0f, 0x1e, 0x44, 0x73, 0xca, 0x72, 0x4c, 0x7f,
+	0x37, 0x94, 0x0b, 0x74, 0x06, 0x5d, 0x56, 0xb2, 0x8c, 0xc6, 0xde, 0xd8, 0x9b, 0x04, 0x58, 0x1b,
+	0xe8, 0x14, 0x3a, 0x77, 0x74, 0x1b, 0xfb, 0x63, 0x6f, 0x32, 0xc0, 0xf2, 0x28, 0xe3, 0xfe, 0x90,
+	0x75, 0x43, 0xe3, 0x8e, 0xc2, 0xb4, 0x81, 0x5e, 0x42, 0x9f, 0xaf, 0x96, 0x8c, 0x88, 0xa6, 0xa6,
+	0x71, 0xa0, 0x3c, 0x7b, 0x00, 0x9d, 0x43, 0xc8, 0xb3, 0x82, 0x6e, 0x68, 0xdc, 0x1d, 0x7b, 0x93,
+	0x21, 0x36, 0x16, 0xba, 0x84, 0x5e, 0x53, 0xe5, 0x44, 0x50, 0x1e, 0x87, 0xe3, 0xce, 0x24, 0x9a,
+	0x45, 0x53, 0xc9, 0xf2, 0x07, 0xdd, 0x2c, 0x68, 0x8d, 0xad, 0x2f, 0xfd, 0x0c, 0x03, 0xcd, 0x94,
+	0x57, 0x25, 0xe3, 0xad, 0x34, 0xef, 0x48, 0xda, 0x35, 0x84, 0xd7, 0xab, 0xa5, 0xd4, 0x66, 0x54,
+	0x78, 0x2d, 0x15, 0x5a, 0xad, 0xef, 0xaa, 0x45, 0x10, 0x14, 0x84, 0x17, 0x46, 0x9a, 0x3a, 0xa7,
+	0x25, 0x44, 0xf3, 0x2d, 0xcb, 0xec, 0x98, 0x2e, 0xa1, 0x97, 0xab, 0xa2, 0xed, 0xde, 0xba, 0x11,
+	0xb6, 0x3e, 0x59, 0xa9, 0x22, 0xa2, 0x30, 0x83, 0x53, 0x67, 0x97, 0x76, 0xe7, 0x08, 0xed, 0xbf,
+	0x1e, 0x0c, 0x74, 0x47, 0x23, 0xf7, 0x3d, 0x3c, 0xdb, 0x50, 0xce, 0xc9, 0x72, 0xa7, 0xf7, 0x54,
+	0x25, 0x3a, 0xb7, 0x87, 0x77, 0x11, 0xe8, 0x02, 0xc2, 0x7b, 0xc2, 0x04, 0xcd, 0x63, 0xff, 0x90,
+	0x9f, 0x71, 0x3d, 0x96, 0xca, 0x05, 0x0c, 0xbf, 0x13, 0x5e, 0x50, 0x6e, 0xd5, 0x5b, 0x59, 0xde,
+	0x5e, 0x56, 0x3a, 0x81, 0x13, 0x1b, 0x64, 0x08, 0x9f, 0x43, 0x58, 0x28, 0x44, 0xd1, 0x1d, 0x60,
+	0x63, 0xa5, 0x5f, 0x20, 0xf8, 0x9a, 0xe7, 0x35, 0x8a, 0xa1, 0xc7, 0xa8, 0xb8, 0x2f, 0xeb, 0x3b,
+	0x55, 0xa8, 0x8f, 0xad, 0xb9, 0x5f, 0x2e, 0x5f, 0xe1, 0xda, 0x48, 0xdf, 0xc2, 0xe0, 0x86, 0xd2,
+	0x9a, 0x3b, 0xab, 0x9a, 0x95, 0x0d, 0x13, 0x2a, 0x7b, 0x88, 0xb5, 0x91, 0x7e, 0x80, 0xa1, 0x89,
+	0x32, 0x34, 0x5e, 0x43, 0x97, 0xe4, 0x79, 0x6d, 0x87, 0xd6, 0x57, 0x12, 0x25, 0x01, 0xac, 0xf1,
+	0xf4, 0x17, 0x84, 0x5a, 0x31, 0x7a, 0x05, 0x81, 0x84, 0x54, 0xc1, 0x56, 0xa4, 0x82, 0x65, 0x43,
+	0x2e, 0x88, 0xd0, 0xb4, 0x86, 0x58, 0x1b, 0x68, 0x0c, 0xd1, 0x8a, 0x65, 0xa4, 0x66, 0x44, 0xac,
+	0x4a, 0xa6, 0x96, 0x26, 0xc0, 0x2e, 0x94, 0xfe, 0x84, 0xe8, 0x66, 0xc5, 0x96, 0x96, 0xf7, 0x1b,
+	0x08, 0x05, 0xa9, 0x97, 0x54, 0x1c, 0xf6, 0x31, 0x0e, 0xf7, 0x62, 0xfc, 0xe3, 0x2f, 0x42, 0x17,
+	0x7e, 0xda, 0x8b, 0xb8, 0x82, 0x93, 0x79, 0xd1, 0xdc, 0xde, 0xae, 0xa9, 0xa5, 0xf4, 0xdf, 0x19,
+	0xcd, 0xe0, 0xf9, 0x2e, 0xe5, 0x91, 0x73, 0x9d, 0x3d, 0xf8, 0x10, 0x7e, 0x23, 0x8b, 0xc5, 0x9a,
+	0xa2, 0x77, 0x10, 0xc8, 0x35, 0x45, 0x07, 0x1b, 0x9b, 0x8c, 0x1c, 0xc4, 0x14, 0x96, 0xc1, 0x5b,
+	0x96, 0xd9, 0xe0, 0xfd, 0xab, 0x4b, 0x46, 0x0e, 0x62, 0x82, 0xaf, 0x20, 0xd4, 0x6b, 0x87, 0x90,
+	0x72, 0xb6, 0x16, 0x35, 0x79, 0xd1, 0xc2, 0x4c, 0xca, 0x14, 0xba, 0x6a, 0x43, 0x90, 0x2e, 0xe7,
+	0xee, 0x54, 0x82, 0x5c, 0x68, 0xcf, 0x47, 0x4e, 0xd9, 0xf0, 0x71, 0x6e, 0x32, 0x19, 0x39, 0x88,
+	0x09, 0xfe, 0x04, 0x3d, 0x33, 0x28, 0xa4, 0x9b, 0xb7, 0x27, 0x9d, 0x9c, 0xb5, 0x41, 0x9d, 0xb5,
+	0x08, 0xd5, 0x8f, 0xfc, 0xf1, 0xdf, 0x00, 0xd4, 0x76, 0x1f, 0x31, 0x9e, 0x05, 0x00, 0x00,
 }
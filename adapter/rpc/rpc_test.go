@@ -13,6 +13,8 @@ import (
 
 	"github.com/republicprotocol/babble-go/core/addr"
 	"github.com/republicprotocol/babble-go/core/gossip"
+	"github.com/republicprotocol/babble-go/core/membership"
+	"github.com/republicprotocol/babble-go/core/view"
 	"github.com/republicprotocol/babble-go/testutils"
 	"github.com/republicprotocol/co-go"
 	"google.golang.org/grpc"
@@ -32,7 +34,7 @@ var _ = Describe("gRPC", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 
 			books[i] = book
-			clients[i] = NewClient(testutils.MockDialer{}, testutils.MockCaller{})
+			clients[i] = NewClient(testutils.MockDialer{}, testutils.MockCaller{}, nil)
 			stores[i] = testutils.NewMockMessages()
 			for j := 0; j < n; j++ {
 				if i == j {
@@ -43,8 +45,8 @@ var _ = Describe("gRPC", func() {
 				Expect(book.InsertAddr(addr)).ShouldNot(HaveOccurred())
 			}
 
-			gossiper := gossip.NewGossiper(books[i], α, testutils.MockSinger{}, testutils.MockVerifier{}, nil, clients[i], stores[i])
-			service := NewService(gossiper)
+			gossiper := gossip.NewGossiper(books[i], α, testutils.MockSinger{}, testutils.MockVerifier{}, nil, nil, nil, clients[i], stores[i])
+			service := NewService(gossiper, nil, nil, nil)
 			servers[i] = grpc.NewServer()
 			service.Register(servers[i])
 
@@ -142,6 +144,353 @@ var _ = Describe("gRPC", func() {
 			})
 		})
 	}
+
+	Context("when synchronising", func() {
+		It("should return the messages that differ", func() {
+			clients, stores, servers, listens := init(1, 2)
+			defer stopService(servers, listens)
+
+			go co.ParForAll(servers, func(i int) {
+				defer GinkgoRecover()
+
+				err := servers[i].Serve(listens[i])
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+			time.Sleep(time.Second)
+
+			newer := gossip.NewMessage(2, []byte("newer"), []byte("value"), []byte("signature"))
+			missing := gossip.NewMessage(1, []byte("missing"), []byte("value"), []byte("signature"))
+			older := gossip.NewMessage(1, []byte("older"), []byte("value"), []byte("signature"))
+			Expect(stores[1].InsertMessage(newer)).ShouldNot(HaveOccurred())
+			Expect(stores[1].InsertMessage(missing)).ShouldNot(HaveOccurred())
+			Expect(stores[1].InsertMessage(older)).ShouldNot(HaveOccurred())
+
+			digests := []gossip.Digest{{Key: newer.Key, Nonce: 1}, {Key: older.Key, Nonce: 2}}
+			to, err := net.ResolveTCPAddr("tcp", "0.0.0.0:8001")
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			messages, wanted, err := clients[0].Sync(ctx, to, nil, digests)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(messages).Should(ConsistOf(newer, missing))
+			Expect(wanted).Should(ConsistOf(digests[1]))
+		})
+	})
+
+	Context("when exchanging peers", func() {
+		It("should return the peers that are known to the remote node", func() {
+			clients, _, servers, listens := init(1, 3)
+			defer stopService(servers, listens)
+
+			go co.ParForAll(servers, func(i int) {
+				defer GinkgoRecover()
+
+				err := servers[i].Serve(listens[i])
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+			time.Sleep(time.Second)
+
+			to, err := net.ResolveTCPAddr("tcp", "0.0.0.0:8001")
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			peers, err := clients[0].Peers(ctx, to, gossip.MaxPeers)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(peers).Should(HaveLen(2))
+			values := make([]string, len(peers))
+			for i := range peers {
+				Expect(peers[i].Network()).Should(Equal("tcp"))
+				values[i] = peers[i].String()
+			}
+			Expect(values).Should(ConsistOf("0.0.0.0:8000", "0.0.0.0:8002"))
+		})
+
+		It("should bootstrap a new node from a seed", func() {
+			_, _, servers, listens := init(1, 3)
+			defer stopService(servers, listens)
+
+			go co.ParForAll(servers, func(i int) {
+				defer GinkgoRecover()
+
+				err := servers[i].Serve(listens[i])
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+			time.Sleep(time.Second)
+
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			seed, err := net.ResolveTCPAddr("tcp", "0.0.0.0:8000")
+			Expect(err).ShouldNot(HaveOccurred())
+			bootstrapper := gossip.NewBootstrapper(nil, book, NewClient(testutils.MockDialer{}, testutils.MockCaller{}, nil), []net.Addr{seed}, 3, time.Minute, nil)
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			Expect(bootstrapper.Bootstrap(ctx)).ShouldNot(HaveOccurred())
+			peers, err := book.Addrs(3)
+			Expect(err).ShouldNot(HaveOccurred())
+			values := make([]string, len(peers))
+			for i := range peers {
+				values[i] = peers[i].String()
+			}
+			Expect(values).Should(ConsistOf("0.0.0.0:8000", "0.0.0.0:8001", "0.0.0.0:8002"))
+		})
+	})
+})
+
+var _ = Describe("gRPC sender", func() {
+
+	Context("when the client advertises its listen address", func() {
+		It("should let the receiver learn the address of the sender", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			gossiper := gossip.NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockVerifier{}, nil, nil, nil, NewClient(testutils.MockDialer{}, testutils.MockCaller{}, nil), testutils.NewMockMessages())
+			service := NewService(gossip.NewLearningServer(gossiper, book, nil), nil, nil, nil)
+			server := grpc.NewServer()
+			service.Register(server)
+			lis, err := net.Listen("tcp", "127.0.0.1:8300")
+			Expect(err).ShouldNot(HaveOccurred())
+			go server.Serve(lis)
+			defer server.Stop()
+			time.Sleep(time.Second)
+
+			to, err := net.ResolveTCPAddr("tcp", "127.0.0.1:8300")
+			Expect(err).ShouldNot(HaveOccurred())
+			listenAddr, err := net.ResolveTCPAddr("tcp", "0.0.0.0:8301")
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			client := NewClientWithListenAddr(testutils.MockDialer{}, testutils.MockCaller{}, listenAddr, nil)
+			Expect(client.Send(ctx, to, randomMessage())).ShouldNot(HaveOccurred())
+			sender, err := net.ResolveTCPAddr("tcp", "127.0.0.1:8301")
+			Expect(err).ShouldNot(HaveOccurred())
+			_, ok := book.Record(sender)
+			Expect(ok).Should(BeTrue())
+
+			// A client that does not advertise its listen address is not
+			// learned
+			addrs, err := book.Addrs(2)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrs).Should(HaveLen(1))
+			Expect(NewClient(testutils.MockDialer{}, testutils.MockCaller{}, nil).Send(ctx, to, randomMessage())).ShouldNot(HaveOccurred())
+			addrs, err = book.Addrs(2)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrs).Should(HaveLen(1))
+		})
+	})
+
+	Context("when the receiver does not accept the message", func() {
+		It("should return a rejection instead of a failure", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			gossiper := gossip.NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, NewClient(testutils.MockDialer{}, testutils.MockCaller{}, nil), testutils.NewMockMessages())
+			service := NewService(gossiper, nil, nil, nil)
+			server := grpc.NewServer()
+			service.Register(server)
+			lis, err := net.Listen("tcp", "127.0.0.1:8302")
+			Expect(err).ShouldNot(HaveOccurred())
+			go server.Serve(lis)
+			defer server.Stop()
+			time.Sleep(time.Second)
+
+			to, err := net.ResolveTCPAddr("tcp", "127.0.0.1:8302")
+			Expect(err).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			client := NewClient(testutils.MockDialer{}, testutils.MockCaller{}, nil)
+
+			// The message is not signed, so it cannot be verified
+			err = client.Send(ctx, to, randomMessage())
+			Expect(err).Should(HaveOccurred())
+			Expect(gossip.IsRejected(err)).Should(BeTrue())
+
+			unreachable, err := net.ResolveTCPAddr("tcp", "127.0.0.1:8303")
+			Expect(err).ShouldNot(HaveOccurred())
+			failCtx, failCancel := context.WithTimeout(context.Background(), time.Second)
+			defer failCancel()
+			err = client.Send(failCtx, unreachable, randomMessage())
+			Expect(err).Should(HaveOccurred())
+			Expect(gossip.IsRejected(err)).Should(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("gRPC lifecycle", func() {
+
+	Context("when starting and stopping the service", func() {
+		It("should only serve while it is started", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			messages := testutils.NewMockMessages()
+			gossiper := gossip.NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockVerifier{}, nil, nil, nil, NewClient(testutils.MockDialer{}, testutils.MockCaller{}, nil), messages)
+			service := NewService(gossiper, nil, nil, nil)
+
+			lis, err := net.Listen("tcp", "127.0.0.1:8400")
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(service.Start(lis)).ShouldNot(HaveOccurred())
+			Expect(service.Start(lis)).Should(Equal(ErrAlreadyStarted))
+			time.Sleep(time.Second)
+
+			to, err := net.ResolveTCPAddr("tcp", "127.0.0.1:8400")
+			Expect(err).ShouldNot(HaveOccurred())
+			client := NewClient(testutils.MockDialer{}, testutils.MockCaller{}, nil)
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			message := randomMessage()
+			Expect(client.Send(ctx, to, message)).ShouldNot(HaveOccurred())
+			stored, err := messages.Message(message.Key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.Nonce).Should(Equal(message.Nonce))
+
+			Expect(service.Stop(ctx)).ShouldNot(HaveOccurred())
+			Expect(gossiper.Stop(ctx)).ShouldNot(HaveOccurred())
+			failCtx, failCancel := context.WithTimeout(context.Background(), time.Second)
+			defer failCancel()
+			Expect(client.Send(failCtx, to, randomMessage())).Should(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("gRPC membership", func() {
+
+	Context("when pinging", func() {
+		It("should ping directly and indirectly, and exchange updates", func() {
+			n := 3
+			addrs := make([]net.Addr, n)
+			members := make([]membership.Membership, n)
+			servers := make([]*grpc.Server, n)
+			for i := 0; i < n; i++ {
+				addr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("0.0.0.0:%v", 8100+i))
+				Expect(err).ShouldNot(HaveOccurred())
+				addrs[i] = addr
+			}
+			for i := 0; i < n; i++ {
+				book, err := addr.NewBook(testutils.NewMockAddrs())
+				Expect(err).ShouldNot(HaveOccurred())
+				members[i] = membership.New(addrs[i], book, NewMembershipClient(testutils.MockDialer{}, testutils.MockCaller{}, nil), 1, time.Second, time.Minute, nil, nil)
+				service := NewService(nil, members[i], nil, nil)
+				servers[i] = grpc.NewServer()
+				service.Register(servers[i])
+
+				lis, err := net.Listen("tcp", addrs[i].String())
+				Expect(err).ShouldNot(HaveOccurred())
+				go servers[i].Serve(lis)
+				defer servers[i].Stop()
+			}
+			time.Sleep(time.Second)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			client := NewMembershipClient(testutils.MockDialer{}, testutils.MockCaller{}, nil)
+			joined := membership.Update{Addr: addrs[2], State: membership.Alive, Incarnation: 1}
+
+			_, err := client.Ping(ctx, addrs[0], []membership.Update{joined})
+			Expect(err).ShouldNot(HaveOccurred())
+			update, ok := members[0].Member(addrs[2])
+			Expect(ok).Should(BeTrue())
+			Expect(update.State).Should(Equal(membership.Alive))
+			Expect(update.Incarnation).Should(Equal(uint64(1)))
+
+			_, err = client.PingIndirect(ctx, addrs[1], addrs[0], nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			update, ok = members[1].Member(addrs[2])
+			Expect(ok).Should(BeTrue())
+			Expect(update.Incarnation).Should(Equal(uint64(1)))
+
+			unreachable, err := net.ResolveTCPAddr("tcp", "0.0.0.0:8199")
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = client.PingIndirect(ctx, addrs[1], unreachable, nil)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when gossiping", func() {
+		It("should piggyback updates onto sends and their responses", func() {
+			newMembership := func(self net.Addr) membership.Membership {
+				book, err := addr.NewBook(testutils.NewMockAddrs())
+				Expect(err).ShouldNot(HaveOccurred())
+				return membership.New(self, book, NewMembershipClient(testutils.MockDialer{}, testutils.MockCaller{}, nil), 1, time.Second, time.Minute, nil, nil)
+			}
+			to, err := net.ResolveTCPAddr("tcp", "127.0.0.1:8500")
+			Expect(err).ShouldNot(HaveOccurred())
+			from, err := net.ResolveTCPAddr("tcp", "127.0.0.1:8501")
+			Expect(err).ShouldNot(HaveOccurred())
+			receiver, sender := newMembership(to), newMembership(from)
+
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			gossiper := gossip.NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockVerifier{}, nil, nil, nil, NewClient(testutils.MockDialer{}, testutils.MockCaller{}, nil), testutils.NewMockMessages())
+			service := NewService(gossiper, receiver, nil, nil)
+			server := grpc.NewServer()
+			service.Register(server)
+			lis, err := net.Listen("tcp", to.String())
+			Expect(err).ShouldNot(HaveOccurred())
+			go server.Serve(lis)
+			defer server.Stop()
+			time.Sleep(time.Second)
+
+			// Each side knows about a member that the other side does not
+			joinedSender, joinedReceiver := testutils.RandomAddr(), testutils.RandomAddr()
+			_, err = sender.Ping(context.Background(), []membership.Update{{Addr: joinedSender, State: membership.Alive, Incarnation: 1}})
+			Expect(err).ShouldNot(HaveOccurred())
+			_, err = receiver.Ping(context.Background(), []membership.Update{{Addr: joinedReceiver, State: membership.Alive, Incarnation: 1}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			client := NewPiggybackingClient(testutils.MockDialer{}, testutils.MockCaller{}, nil, sender, nil)
+			Expect(client.Send(ctx, to, randomMessage())).ShouldNot(HaveOccurred())
+
+			update, ok := receiver.Member(joinedSender)
+			Expect(ok).Should(BeTrue())
+			Expect(update.Incarnation).Should(Equal(uint64(1)))
+			update, ok = sender.Member(joinedReceiver)
+			Expect(ok).Should(BeTrue())
+			Expect(update.Incarnation).Should(Equal(uint64(1)))
+		})
+	})
+})
+
+var _ = Describe("gRPC view", func() {
+
+	Context("when shuffling", func() {
+		It("should exchange addresses with the remote view", func() {
+			local, err := net.ResolveTCPAddr("tcp", "0.0.0.0:8200")
+			Expect(err).ShouldNot(HaveOccurred())
+			remote, err := net.ResolveTCPAddr("tcp", "0.0.0.0:8201")
+			Expect(err).ShouldNot(HaveOccurred())
+			known, err := net.ResolveTCPAddr("tcp", "0.0.0.0:8202")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			client := NewViewClient(testutils.MockDialer{}, testutils.MockCaller{}, nil)
+			remoteView, err := view.New(remote, testutils.NewMockAddrs(), client, 1, 4, 2, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(remoteView.InsertAddr(local)).ShouldNot(HaveOccurred())
+			Expect(remoteView.InsertAddr(known)).ShouldNot(HaveOccurred())
+
+			service := NewService(nil, nil, remoteView, nil)
+			server := grpc.NewServer()
+			service.Register(server)
+			lis, err := net.Listen("tcp", remote.String())
+			Expect(err).ShouldNot(HaveOccurred())
+			go server.Serve(lis)
+			defer server.Stop()
+			time.Sleep(time.Second)
+
+			localView, err := view.New(local, testutils.NewMockAddrs(), client, 1, 4, 2, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(localView.InsertAddr(remote)).ShouldNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+
+			Expect(localView.ShuffleView(ctx)).ShouldNot(HaveOccurred())
+			passive := localView.Passive()
+			Expect(passive).Should(HaveLen(1))
+			Expect(passive[0].String()).Should(Equal(known.String()))
+		})
+	})
 })
 
 // randomMessage returns a random message.
@@ -1,29 +1,80 @@
 package babble
 
 import (
+	"github.com/republicprotocol/babble-go/adapter/crypto"
 	"github.com/republicprotocol/babble-go/adapter/db"
+	"github.com/republicprotocol/babble-go/adapter/keystore"
 	"github.com/republicprotocol/babble-go/adapter/rpc"
 	"github.com/republicprotocol/babble-go/core/addr"
 	"github.com/republicprotocol/babble-go/core/gossip"
+	"github.com/republicprotocol/babble-go/core/logging"
+	"github.com/republicprotocol/babble-go/core/membership"
+	"github.com/republicprotocol/babble-go/core/view"
 )
 
 type (
-	Addrs    = addr.Addrs
-	AddrBook = addr.Book
-	Messages = gossip.Messages
-	Gossiper = gossip.Gossiper
-	Message  = gossip.Message
-	Client   = gossip.Client
-	Observer = gossip.Observer
-	Signer   = gossip.Signer
-	Verifier = gossip.Verifier
+	Addrs        = addr.Addrs
+	AddrBook     = addr.Book
+	SourcedBook  = addr.SourcedBook
+	Record       = addr.Record
+	Messages     = gossip.Messages
+	Tree         = gossip.Tree
+	Digest       = gossip.Digest
+	Gossiper     = gossip.Gossiper
+	Message      = gossip.Message
+	Report       = gossip.Report
+	Delivery     = gossip.Delivery
+	Client       = gossip.Client
+	Outbound     = gossip.Outbound
+	Observer     = gossip.Observer
+	Signer       = gossip.Signer
+	Verifier     = gossip.Verifier
+	Signatory    = gossip.Signatory
+	Scheme       = gossip.Scheme
+	Delegations  = gossip.Delegations
+	Resolver     = gossip.Resolver
+	PeerSelector = gossip.PeerSelector
+	Membership   = membership.Membership
+	View         = view.View
+	Logger       = logging.Logger
 )
 
 var (
-	NewDb         = db.New
-	NewBook       = addr.NewBook
-	NewGossiper   = gossip.NewGossiper
-	NewMessage    = gossip.NewMessage
-	NewRPCClient  = rpc.NewClient
-	NewRPCService = rpc.NewService
+	NewDb                      = db.New
+	MigrateDb                  = db.Migrate
+	NewBook                    = addr.NewBook
+	NewBookWithSource          = addr.NewBookWithSource
+	NewBucketedBook            = addr.NewBucketedBook
+	NewBucketedBookWithSource  = addr.NewBucketedBookWithSource
+	NewGossiper                = gossip.NewGossiper
+	WithSelector               = gossip.WithSelector
+	WithOutbound               = gossip.WithOutbound
+	WithLogger                 = gossip.WithLogger
+	WithSelf                   = gossip.WithSelf
+	NewLearningServer          = gossip.NewLearningServer
+	NewOutbound                = gossip.NewOutbound
+	NewBootstrapper            = gossip.NewBootstrapper
+	NewTree                    = gossip.NewTree
+	NewMessage                 = gossip.NewMessage
+	NewHashResolver            = gossip.NewHashResolver
+	NewRandomSelector          = gossip.NewRandomSelector
+	NewRPCClient               = rpc.NewClient
+	NewRPCClientWithListenAddr = rpc.NewClientWithListenAddr
+	NewRPCPiggybackingClient   = rpc.NewPiggybackingClient
+	NewRPCService              = rpc.NewService
+	NewMembership              = membership.New
+	NewRPCMembershipClient     = rpc.NewMembershipClient
+	NewView                    = view.New
+	NewRPCViewClient           = rpc.NewViewClient
+
+	NewVerifier          = crypto.NewVerifier
+	NewSecp256k1Signer   = crypto.NewSecp256k1Signer
+	NewSecp256k1Verifier = crypto.NewSecp256k1Verifier
+	NewEd25519Signer     = crypto.NewEd25519Signer
+	NewEd25519Verifier   = crypto.NewEd25519Verifier
+	NewKeystore          = keystore.New
+
+	NewStdLogger     = logging.NewStdLogger
+	NewNopLogger     = logging.NewNopLogger
+	NewDefaultLogger = logging.NewDefaultLogger
 )
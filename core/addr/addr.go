@@ -1,10 +1,61 @@
 package addr
 
 import (
+	"errors"
+	"math/rand"
 	"net"
+	"sort"
 	"sync"
+	"time"
 )
 
+// MaxFailures is the number of consecutive failures after which a `net.Addr`
+// is evicted from a Book.
+const MaxFailures = 3
+
+// ErrAddrNotFound is returned when a `net.Addr` is not in a Book.
+var ErrAddrNotFound = errors.New("addr not found")
+
+// A Record holds the metadata of a `net.Addr`, and tracks whether or not it is
+// still alive.
+type Record struct {
+
+	// Identity of the peer at the `net.Addr`, such as its public key. It is
+	// nil if the identity is not known.
+	Identity []byte `json:"identity,omitempty"`
+
+	// FirstSeen is the time that the `net.Addr` was inserted.
+	FirstSeen time.Time `json:"firstSeen"`
+
+	// LastSeen is the last time that the `net.Addr` responded, or the time
+	// that it was inserted if it has never responded.
+	LastSeen time.Time `json:"lastSeen"`
+
+	// LastSent is the last time that a Message was successfully sent to the
+	// `net.Addr`. It is zero if no Message has been sent.
+	LastSent time.Time `json:"lastSent"`
+
+	// Failures is the number of consecutive times that the `net.Addr` has
+	// failed to respond.
+	Failures int `json:"failures"`
+
+	// ProtocolVersion spoken by the peer at the `net.Addr`. It is zero if the
+	// version is not known.
+	ProtocolVersion uint32 `json:"protocolVersion,omitempty"`
+
+	// Tags are free-form labels that applications can attach to the
+	// `net.Addr`.
+	Tags []string `json:"tags,omitempty"`
+
+	// Seed is true if the `net.Addr` is a seed node. Seed nodes are never
+	// evicted or expired from a Book.
+	Seed bool `json:"seed,omitempty"`
+
+	// Source is the `net.Addr` of the peer from which the `net.Addr` was
+	// learned. It is empty if the source is not known.
+	Source string `json:"source,omitempty"`
+}
+
 // Addrs is used to store and lookup all known `net.Addr`. It is not assumed
 // that this interface is safe for concurrent use.
 type Addrs interface {
@@ -12,8 +63,20 @@ type Addrs interface {
 	// Insert a new Addr to the store.
 	InsertAddr(net.Addr) error
 
+	// RemoveAddr from the store, along with its Record. Removing an Addr
+	// that is not in the store does nothing.
+	RemoveAddr(net.Addr) error
+
 	// Store returns all the stored Store.
 	Addrs() ([]net.Addr, error)
+
+	// InsertRecord for an Addr into the store, overwriting any existing
+	// Record for the Addr.
+	InsertRecord(net.Addr, Record) error
+
+	// Record returns the Record for an Addr. It returns an empty Record if
+	// there is no Record for the Addr.
+	Record(net.Addr) (Record, error)
 }
 
 // Book is used to provide a fast lookup of random `net.Addr` that can be used
@@ -23,57 +86,298 @@ type Book interface {
 	// InsertAddr into the Book.
 	InsertAddr(net.Addr) error
 
+	// RemoveAddr from the Book.
+	RemoveAddr(net.Addr) error
+
 	// Store returns α random `net.Addr` from the set of all known `net.Addr`
 	// to the Book.
 	Addrs(α int) ([]net.Addr, error)
+
+	// Record returns the Record of a `net.Addr`, and false if the `net.Addr`
+	// is not in the Book.
+	Record(net.Addr) (Record, bool)
+
+	// InsertRecord for a `net.Addr`, overwriting its existing Record. It
+	// returns ErrAddrNotFound if the `net.Addr` is not in the Book.
+	InsertRecord(net.Addr, Record) error
+
+	// Seen records that a `net.Addr` has responded, and resets its failures.
+	// It does nothing if the `net.Addr` is not in the Book.
+	Seen(net.Addr) error
+
+	// Sent records that a Message was successfully sent to a `net.Addr`,
+	// which also counts as the `net.Addr` having responded. It does nothing
+	// if the `net.Addr` is not in the Book.
+	Sent(net.Addr) error
+
+	// Failed records that a `net.Addr` has failed to respond. After
+	// MaxFailures consecutive failures, the `net.Addr` is removed from the
+	// Book, unless it is a seed. It does nothing if the `net.Addr` is not in
+	// the Book.
+	Failed(net.Addr) error
+
+	// Expire removes all `net.Addr`, except seeds, that have not been seen
+	// since the `lastSeen` time.
+	Expire(lastSeen time.Time) error
 }
 
 type book struct {
 	addrsMu    *sync.RWMutex
-	addrsCache map[string]net.Addr
+	addrsIndex map[string]int
+	addrsCache []net.Addr
+	records    map[string]Record
 	addrs      Addrs
+
+	randMu *sync.Mutex
+	rand   *rand.Rand
 }
 
-// NewBook returns a new Book with given addr Store.
+// NewBook returns a new Book with given addr Store. The Book samples `net.Addr`
+// using a random source that is seeded with the current time.
 func NewBook(addrs Addrs) (Book, error) {
+	return NewBookWithSource(addrs, rand.NewSource(time.Now().UnixNano()))
+}
+
+// NewBookWithSource returns a new Book with given addr Store that samples
+// `net.Addr` using the random `source`. Books that are created from the same
+// `net.Addr`, and with equally seeded sources, return the same samples. The
+// `source` must not be used by anything else after it is given to the Book.
+func NewBookWithSource(addrs Addrs, source rand.Source) (Book, error) {
 	allKnownAddrs, err := addrs.Addrs()
 	if err != nil {
 		return nil, err
 	}
 
-	addrsCache := make(map[string]net.Addr, len(allKnownAddrs))
-	for _, addr := range allKnownAddrs {
-		addrsCache[addr.String()] = addr
-	}
+	// Sort the addresses so that the order of the cache does not depend on
+	// the order in which they were loaded from the store
+	sort.Slice(allKnownAddrs, func(i, j int) bool {
+		return allKnownAddrs[i].String() < allKnownAddrs[j].String()
+	})
 
-	return &book{
+	book := &book{
 		addrsMu:    new(sync.RWMutex),
-		addrsCache: addrsCache,
+		addrsIndex: make(map[string]int, len(allKnownAddrs)),
+		addrsCache: make([]net.Addr, 0, len(allKnownAddrs)),
+		records:    make(map[string]Record, len(allKnownAddrs)),
 		addrs:      addrs,
-	}, nil
+
+		randMu: new(sync.Mutex),
+		rand:   rand.New(source),
+	}
+	now := time.Now()
+	for _, addr := range allKnownAddrs {
+		record, err := addrs.Record(addr)
+		if err != nil {
+			return nil, err
+		}
+		// Addresses that were stored before Records were stored have never
+		// been seen, so they are treated as if they were inserted now,
+		// otherwise the first call to Expire would remove all of them
+		if record.LastSeen.IsZero() {
+			if record.FirstSeen.IsZero() {
+				record.FirstSeen = now
+			}
+			record.LastSeen = now
+			if err := addrs.InsertRecord(addr, record); err != nil {
+				return nil, err
+			}
+		}
+		book.insertAddr(addr)
+		book.records[addr.String()] = record
+	}
+	return book, nil
 }
 
-// InsertAddr implements Store interface.
+// InsertAddr implements Store interface. Inserting a `net.Addr` that is not
+// already in the Book creates a Record for it.
 func (book *book) InsertAddr(addr net.Addr) error {
 	book.addrsMu.Lock()
 	defer book.addrsMu.Unlock()
 
-	book.addrsCache[addr.String()] = addr
-	return book.addrs.InsertAddr(addr)
+	if err := book.addrs.InsertAddr(addr); err != nil {
+		return err
+	}
+	if _, ok := book.addrsIndex[addr.String()]; !ok {
+		now := time.Now()
+		if err := book.insertRecord(addr, Record{FirstSeen: now, LastSeen: now}); err != nil {
+			return err
+		}
+	}
+	book.insertAddr(addr)
+	return nil
+}
+
+// RemoveAddr implements Store interface.
+func (book *book) RemoveAddr(addr net.Addr) error {
+	book.addrsMu.Lock()
+	defer book.addrsMu.Unlock()
+
+	return book.removeAddr(addr)
 }
 
-// Addrs implements Store interface.
+// Addrs implements Store interface. It returns a uniformly random sample of
+// `net.Addr`, without replacement, using a partial Fisher-Yates shuffle.
 func (book *book) Addrs(α int) ([]net.Addr, error) {
 	book.addrsMu.RLock()
 	defer book.addrsMu.RUnlock()
 
 	addrs := make([]net.Addr, 0, α)
-	for _, addr := range book.addrsCache {
-		if len(addrs) >= α {
-			return addrs, nil
+	n := len(book.addrsCache)
+	if α > n {
+		α = n
+	}
+
+	// Swaps are recorded instead of applied, so that the cache is not
+	// modified, and so that sampling does not need to copy the cache
+	swapped := make(map[int]int, α)
+	at := func(i int) int {
+		if j, ok := swapped[i]; ok {
+			return j
 		}
-		addrs = append(addrs, addr)
+		return i
+	}
+
+	book.randMu.Lock()
+	defer book.randMu.Unlock()
+
+	for i := 0; i < α; i++ {
+		j := i + book.rand.Intn(n-i)
+		addrs = append(addrs, book.addrsCache[at(j)])
+		swapped[j] = at(i)
 	}
 
 	return addrs, nil
 }
+
+// Record implements Store interface.
+func (book *book) Record(addr net.Addr) (Record, bool) {
+	book.addrsMu.RLock()
+	defer book.addrsMu.RUnlock()
+
+	if _, ok := book.addrsIndex[addr.String()]; !ok {
+		return Record{}, false
+	}
+	return book.records[addr.String()].clone(), true
+}
+
+// InsertRecord implements Store interface.
+func (book *book) InsertRecord(addr net.Addr, record Record) error {
+	book.addrsMu.Lock()
+	defer book.addrsMu.Unlock()
+
+	if _, ok := book.addrsIndex[addr.String()]; !ok {
+		return ErrAddrNotFound
+	}
+	return book.insertRecord(addr, record.clone())
+}
+
+// Seen implements Store interface.
+func (book *book) Seen(addr net.Addr) error {
+	book.addrsMu.Lock()
+	defer book.addrsMu.Unlock()
+
+	if _, ok := book.addrsIndex[addr.String()]; !ok {
+		return nil
+	}
+	record := book.records[addr.String()]
+	record.LastSeen = time.Now()
+	record.Failures = 0
+	return book.insertRecord(addr, record)
+}
+
+// Sent implements Store interface.
+func (book *book) Sent(addr net.Addr) error {
+	book.addrsMu.Lock()
+	defer book.addrsMu.Unlock()
+
+	if _, ok := book.addrsIndex[addr.String()]; !ok {
+		return nil
+	}
+	record := book.records[addr.String()]
+	record.LastSeen = time.Now()
+	record.LastSent = record.LastSeen
+	record.Failures = 0
+	return book.insertRecord(addr, record)
+}
+
+// Failed implements Store interface.
+func (book *book) Failed(addr net.Addr) error {
+	book.addrsMu.Lock()
+	defer book.addrsMu.Unlock()
+
+	if _, ok := book.addrsIndex[addr.String()]; !ok {
+		return nil
+	}
+	record := book.records[addr.String()]
+	record.Failures++
+	if record.Failures >= MaxFailures && !record.Seed {
+		return book.removeAddr(addr)
+	}
+	return book.insertRecord(addr, record)
+}
+
+// Expire implements Store interface.
+func (book *book) Expire(lastSeen time.Time) error {
+	book.addrsMu.Lock()
+	defer book.addrsMu.Unlock()
+
+	expired := make([]net.Addr, 0)
+	for _, addr := range book.addrsCache {
+		record := book.records[addr.String()]
+		if record.LastSeen.Before(lastSeen) && !record.Seed {
+			expired = append(expired, addr)
+		}
+	}
+	for _, addr := range expired {
+		if err := book.removeAddr(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (book *book) insertRecord(addr net.Addr, record Record) error {
+	if err := book.addrs.InsertRecord(addr, record); err != nil {
+		return err
+	}
+	book.records[addr.String()] = record
+	return nil
+}
+
+func (book *book) insertAddr(addr net.Addr) {
+	if i, ok := book.addrsIndex[addr.String()]; ok {
+		book.addrsCache[i] = addr
+		return
+	}
+	book.addrsIndex[addr.String()] = len(book.addrsCache)
+	book.addrsCache = append(book.addrsCache, addr)
+}
+
+func (book *book) removeAddr(addr net.Addr) error {
+	if err := book.addrs.RemoveAddr(addr); err != nil {
+		return err
+	}
+
+	i, ok := book.addrsIndex[addr.String()]
+	if !ok {
+		return nil
+	}
+	last := book.addrsCache[len(book.addrsCache)-1]
+	book.addrsCache[i] = last
+	book.addrsIndex[last.String()] = i
+	book.addrsCache = book.addrsCache[:len(book.addrsCache)-1]
+	delete(book.addrsIndex, addr.String())
+	delete(book.records, addr.String())
+	return nil
+}
+
+// clone the Record so that the Book does not share its slices with callers.
+func (record Record) clone() Record {
+	if record.Identity != nil {
+		record.Identity = append([]byte{}, record.Identity...)
+	}
+	if record.Tags != nil {
+		record.Tags = append([]string{}, record.Tags...)
+	}
+	return record
+}
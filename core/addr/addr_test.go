@@ -2,6 +2,7 @@ package addr_test
 
 import (
 	"math/rand"
+	"net"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -113,6 +114,196 @@ var _ = Describe("Store", func() {
 
 	})
 
+	Context("when sampling addresses", func() {
+
+		It("should return the same samples from equally seeded sources", func() {
+			addrs := testutils.NewMockAddrs()
+			for i := 0; i < 100; i++ {
+				Expect(addrs.InsertAddr(testutils.RandomAddr())).ShouldNot(HaveOccurred())
+			}
+			book, err := NewBookWithSource(addrs, rand.NewSource(1))
+			Expect(err).ShouldNot(HaveOccurred())
+			other, err := NewBookWithSource(addrs, rand.NewSource(1))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			for i := 0; i < 10; i++ {
+				sample, err := book.Addrs(10)
+				Expect(err).ShouldNot(HaveOccurred())
+				otherSample, err := other.Addrs(10)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(sample).Should(Equal(otherSample))
+			}
+		})
+
+		It("should choose every address with equal probability", func() {
+			book, err := NewBookWithSource(testutils.NewMockAddrs(), rand.NewSource(1))
+			Expect(err).ShouldNot(HaveOccurred())
+			numberOfTestAddrs := 10
+			for i := 0; i < numberOfTestAddrs; i++ {
+				Expect(book.InsertAddr(testutils.RandomAddr())).ShouldNot(HaveOccurred())
+			}
+
+			// Count how often each address is chosen, and how often each
+			// address is chosen first
+			α, samples := 3, 30000
+			counts := map[string]int{}
+			firsts := map[string]int{}
+			for i := 0; i < samples; i++ {
+				sample, err := book.Addrs(α)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(sample).Should(HaveLen(α))
+				for _, addr := range sample {
+					counts[addr.String()]++
+				}
+				firsts[sample[0].String()]++
+			}
+			Expect(counts).Should(HaveLen(numberOfTestAddrs))
+			Expect(firsts).Should(HaveLen(numberOfTestAddrs))
+
+			// The critical value of the chi-squared distribution with 9
+			// degrees of freedom at a significance level of 0.001
+			critical := 27.877
+			Expect(chiSquared(counts, float64(samples*α)/float64(numberOfTestAddrs))).Should(BeNumerically("<", critical))
+			Expect(chiSquared(firsts, float64(samples)/float64(numberOfTestAddrs))).Should(BeNumerically("<", critical))
+		})
+	})
+
+	Context("when removing addresses", func() {
+
+		It("should not return removed addresses", func() {
+			book := newEmptyBook()
+			removed, kept := testutils.RandomAddr(), testutils.RandomAddr()
+			Expect(book.InsertAddr(removed)).ShouldNot(HaveOccurred())
+			Expect(book.InsertAddr(kept)).ShouldNot(HaveOccurred())
+			Expect(book.RemoveAddr(removed)).ShouldNot(HaveOccurred())
+			Expect(book.RemoveAddr(removed)).ShouldNot(HaveOccurred())
+
+			addrs, err := book.Addrs(2)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrs).Should(Equal([]net.Addr{kept}))
+		})
+
+		It("should evict an address after too many consecutive failures", func() {
+			addrs := testutils.NewMockAddrs()
+			book, err := NewBook(addrs)
+			Expect(err).ShouldNot(HaveOccurred())
+			addr := testutils.RandomAddr()
+			Expect(book.InsertAddr(addr)).ShouldNot(HaveOccurred())
+
+			for i := 0; i < MaxFailures-1; i++ {
+				Expect(book.Failed(addr)).ShouldNot(HaveOccurred())
+			}
+			Expect(book.Seen(addr)).ShouldNot(HaveOccurred())
+			for i := 0; i < MaxFailures-1; i++ {
+				Expect(book.Failed(addr)).ShouldNot(HaveOccurred())
+			}
+			record, err := addrs.Record(addr)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(record.Failures).Should(Equal(MaxFailures - 1))
+			Expect(book.Addrs(1)).Should(HaveLen(1))
+
+			Expect(book.Failed(addr)).ShouldNot(HaveOccurred())
+			Expect(book.Addrs(1)).Should(BeEmpty())
+			Expect(addrs.Addrs()).Should(BeEmpty())
+		})
+
+		It("should expire addresses that have not been seen recently", func() {
+			addrs := testutils.NewMockAddrs()
+			expired, seen := testutils.RandomAddr(), testutils.RandomAddr()
+			Expect(addrs.InsertAddr(expired)).ShouldNot(HaveOccurred())
+			Expect(addrs.InsertRecord(expired, Record{LastSeen: time.Now().Add(-time.Hour)})).ShouldNot(HaveOccurred())
+			Expect(addrs.InsertAddr(seen)).ShouldNot(HaveOccurred())
+			Expect(addrs.InsertRecord(seen, Record{LastSeen: time.Now()})).ShouldNot(HaveOccurred())
+			book, err := NewBook(addrs)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(book.Expire(time.Now().Add(-time.Minute))).ShouldNot(HaveOccurred())
+			Expect(book.Addrs(2)).Should(Equal([]net.Addr{seen}))
+			Expect(addrs.Addrs()).Should(Equal([]net.Addr{seen}))
+		})
+
+		It("should not expire addresses that were stored without a record", func() {
+			addrs := testutils.NewMockAddrs()
+			legacy := testutils.RandomAddr()
+			Expect(addrs.InsertAddr(legacy)).ShouldNot(HaveOccurred())
+			book, err := NewBook(addrs)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			stored, err := addrs.Record(legacy)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.FirstSeen.IsZero()).Should(BeFalse())
+			Expect(stored.LastSeen.IsZero()).Should(BeFalse())
+
+			Expect(book.Expire(time.Now().Add(-time.Minute))).ShouldNot(HaveOccurred())
+			Expect(book.Addrs(1)).Should(Equal([]net.Addr{legacy}))
+			Expect(addrs.Addrs()).Should(Equal([]net.Addr{legacy}))
+		})
+	})
+
+	Context("when reading and writing records", func() {
+
+		It("should create a record when inserting a new address", func() {
+			book := newEmptyBook()
+			addr := testutils.RandomAddr()
+			Expect(book.InsertAddr(addr)).ShouldNot(HaveOccurred())
+
+			record, ok := book.Record(addr)
+			Expect(ok).Should(BeTrue())
+			Expect(record.FirstSeen.IsZero()).Should(BeFalse())
+			Expect(record.LastSeen).Should(Equal(record.FirstSeen))
+			Expect(record.LastSent.IsZero()).Should(BeTrue())
+
+			_, ok = book.Record(testutils.RandomAddr())
+			Expect(ok).Should(BeFalse())
+		})
+
+		It("should store the metadata of an address", func() {
+			addrs := testutils.NewMockAddrs()
+			book, err := NewBook(addrs)
+			Expect(err).ShouldNot(HaveOccurred())
+			addr := testutils.RandomAddr()
+			Expect(book.InsertAddr(addr)).ShouldNot(HaveOccurred())
+
+			record, _ := book.Record(addr)
+			record.Identity = []byte("identity")
+			record.ProtocolVersion = 1
+			record.Tags = []string{"seed"}
+			Expect(book.InsertRecord(addr, record)).ShouldNot(HaveOccurred())
+			Expect(book.InsertRecord(testutils.RandomAddr(), record)).Should(Equal(ErrAddrNotFound))
+
+			record.Tags[0] = "modified"
+			stored, _ := book.Record(addr)
+			Expect(stored.Tags).Should(Equal([]string{"seed"}))
+			Expect(addrs.Record(addr)).Should(Equal(stored))
+
+			// The metadata is loaded when the book is created again
+			book, err = NewBook(addrs)
+			Expect(err).ShouldNot(HaveOccurred())
+			loaded, ok := book.Record(addr)
+			Expect(ok).Should(BeTrue())
+			Expect(loaded).Should(Equal(stored))
+		})
+
+		It("should record successful sends without changing the metadata", func() {
+			book := newEmptyBook()
+			addr := testutils.RandomAddr()
+			Expect(book.InsertAddr(addr)).ShouldNot(HaveOccurred())
+			record, _ := book.Record(addr)
+			record.Identity = []byte("identity")
+			Expect(book.InsertRecord(addr, record)).ShouldNot(HaveOccurred())
+
+			Expect(book.Failed(addr)).ShouldNot(HaveOccurred())
+			Expect(book.Sent(addr)).ShouldNot(HaveOccurred())
+
+			sent, _ := book.Record(addr)
+			Expect(sent.Identity).Should(Equal(record.Identity))
+			Expect(sent.FirstSeen).Should(Equal(record.FirstSeen))
+			Expect(sent.LastSent.IsZero()).Should(BeFalse())
+			Expect(sent.LastSeen).Should(Equal(sent.LastSent))
+			Expect(sent.Failures).Should(BeZero())
+		})
+	})
+
 	Context("concurrent use cases", func() {
 
 		It("should be concurrent-safe to inserting and retrieving addrs", func() {
@@ -140,3 +331,11 @@ var _ = Describe("Store", func() {
 	})
 
 })
+
+func chiSquared(observed map[string]int, expected float64) float64 {
+	sum := 0.0
+	for _, n := range observed {
+		sum += (float64(n) - expected) * (float64(n) - expected) / expected
+	}
+	return sum
+}
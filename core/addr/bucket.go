@@ -0,0 +1,572 @@
+package addr
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// NewBucketCount is the number of buckets in the table of `net.Addr` that
+	// have never responded.
+	NewBucketCount = 1024
+
+	// TriedBucketCount is the number of buckets in the table of `net.Addr`
+	// that have responded.
+	TriedBucketCount = 256
+
+	// BucketSize is the maximum number of `net.Addr` in one bucket.
+	BucketSize = 64
+
+	// NewBucketsPerSourceGroup is the number of new buckets that `net.Addr`
+	// learned from one source group can be stored in.
+	NewBucketsPerSourceGroup = 64
+
+	// TriedBucketsPerGroup is the number of tried buckets that `net.Addr` from
+	// one group can be stored in.
+	TriedBucketsPerGroup = 8
+)
+
+// A SourcedBook is a Book that records the source from which every `net.Addr`
+// was learned.
+type SourcedBook interface {
+	Book
+
+	// InsertAddrFrom inserts a `net.Addr` that was learned from the `source`.
+	InsertAddrFrom(addr, source net.Addr) error
+}
+
+type location struct {
+	tried    bool
+	bucket   int
+	position int
+}
+
+type bucketedBook struct {
+	Book
+
+	key []byte
+
+	mu        *sync.Mutex
+	rand      *rand.Rand
+	locations map[string]location
+	newTable  *table
+	tried     *table
+}
+
+// NewBucketedBook returns a SourcedBook that stores `net.Addr` in buckets, in
+// the same way as the address manager of Bitcoin, so that an attacker cannot
+// take over the samples of the Book by flooding it with `net.Addr`.
+//
+// A `net.Addr` that has never responded is stored in a new bucket, chosen by a
+// salted hash of its group and the group of its source. A group is the /16 of
+// an IPv4 address, or the /32 of an IPv6 address. A `net.Addr` that has
+// responded is moved to a tried bucket, chosen by a salted hash of its group.
+// When a `net.Addr` hashes to a position that is already taken, it is dropped,
+// unless the existing `net.Addr` has failed to respond. Samples are drawn from
+// random buckets of both tables, so the `net.Addr` from one group, or from one
+// source group, can only ever be a bounded share of a sample.
+//
+// Seeds are never dropped. A seed takes its position from any `net.Addr` that
+// is not a seed, and a seed whose position is taken by another seed is kept in
+// the Book, but is not sampled.
+//
+// The `key` salts the hashes and must be kept secret. When it is nil, a random
+// key is used. Stored `net.Addr` that do not fit into a bucket are removed
+// from the store. The Book samples `net.Addr` using a random source that is
+// seeded with the current time.
+func NewBucketedBook(addrs Addrs, key []byte) (SourcedBook, error) {
+	return NewBucketedBookWithSource(addrs, key, rand.NewSource(time.Now().UnixNano()))
+}
+
+// NewBucketedBookWithSource returns a SourcedBook like NewBucketedBook, that
+// samples `net.Addr` using the random `source`. Books that are created with
+// the same `key`, from the same `net.Addr`, and with equally seeded sources,
+// store and sample the same `net.Addr`. The `source` must not be used by
+// anything else after it is given to the Book.
+func NewBucketedBookWithSource(addrs Addrs, key []byte, source rand.Source) (SourcedBook, error) {
+	if key == nil {
+		key = make([]byte, 32)
+		if _, err := cryptorand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	r := rand.New(source)
+	book, err := NewBookWithSource(addrs, rand.NewSource(r.Int63()))
+	if err != nil {
+		return nil, err
+	}
+	allKnownAddrs, err := addrs.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(allKnownAddrs, func(i, j int) bool {
+		return allKnownAddrs[i].String() < allKnownAddrs[j].String()
+	})
+
+	bucketedBook := &bucketedBook{
+		Book: book,
+
+		key: key,
+
+		mu:        new(sync.Mutex),
+		rand:      r,
+		locations: map[string]location{},
+		newTable:  newTable(),
+		tried:     newTable(),
+	}
+	for _, addr := range allKnownAddrs {
+		record, ok := book.Record(addr)
+		if !ok {
+			continue
+		}
+		if responded(record) {
+			if err := bucketedBook.insertTried(addr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if record.Seed {
+			if err := bucketedBook.insertSeed(addr); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		ok, err := bucketedBook.insertNew(addr, record.Source)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			if err := book.RemoveAddr(addr); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return bucketedBook, nil
+}
+
+// InsertAddr implements the Book interface. The `net.Addr` is treated as its
+// own source.
+func (book *bucketedBook) InsertAddr(addr net.Addr) error {
+	return book.InsertAddrFrom(addr, addr)
+}
+
+// InsertAddrFrom implements the SourcedBook interface.
+func (book *bucketedBook) InsertAddrFrom(addr, source net.Addr) error {
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	if _, ok := book.locations[addr.String()]; ok {
+		return book.Book.InsertAddr(addr)
+	}
+
+	bucket, position := book.newPosition(addr.String(), source.String())
+	ok, err := book.free(book.newTable, bucket, position)
+	if err != nil || !ok {
+		return err
+	}
+	if err := book.Book.InsertAddr(addr); err != nil {
+		return err
+	}
+	record, _ := book.Book.Record(addr)
+	record.Source = source.String()
+	if err := book.Book.InsertRecord(addr, record); err != nil {
+		return err
+	}
+	book.newTable.set(bucket, position, addr)
+	book.locations[addr.String()] = location{false, bucket, position}
+	return nil
+}
+
+// InsertRecord implements the Book interface. A Record that marks a `net.Addr`
+// as a seed inserts the `net.Addr`, even if it was dropped when it was
+// inserted, so that seeds are never lost.
+func (book *bucketedBook) InsertRecord(addr net.Addr, record Record) error {
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	if record.Seed {
+		if err := book.insertSeed(addr); err != nil {
+			return err
+		}
+	}
+	return book.Book.InsertRecord(addr, record)
+}
+
+// RemoveAddr implements the Book interface.
+func (book *bucketedBook) RemoveAddr(addr net.Addr) error {
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	return book.remove(addr)
+}
+
+// Addrs implements the Book interface. Every `net.Addr` is drawn from a random
+// bucket of a random table.
+func (book *bucketedBook) Addrs(α int) ([]net.Addr, error) {
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	addrs := make([]net.Addr, 0, α)
+	if α >= len(book.locations) {
+		for _, table := range []*table{book.tried, book.newTable} {
+			addrs = append(addrs, table.addrs()...)
+		}
+		book.rand.Shuffle(len(addrs), func(i, j int) {
+			addrs[i], addrs[j] = addrs[j], addrs[i]
+		})
+		return addrs, nil
+	}
+
+	sampled := make(map[string]struct{}, α)
+	for attempt := 0; len(addrs) < α && attempt < 32*(α+1); attempt++ {
+		table := book.newTable
+		if book.tried.size > 0 && (book.newTable.size == 0 || book.rand.Intn(2) == 0) {
+			table = book.tried
+		}
+		addr := table.random(book.rand)
+		if _, ok := sampled[addr.String()]; ok {
+			continue
+		}
+		sampled[addr.String()] = struct{}{}
+		addrs = append(addrs, addr)
+	}
+
+	// When too many draws were duplicates, the sample is filled with
+	// `net.Addr` from the tried table, and then from the new table
+	for _, table := range []*table{book.tried, book.newTable} {
+		for _, addr := range table.addrs() {
+			if len(addrs) >= α {
+				return addrs, nil
+			}
+			if _, ok := sampled[addr.String()]; !ok {
+				sampled[addr.String()] = struct{}{}
+				addrs = append(addrs, addr)
+			}
+		}
+	}
+	return addrs, nil
+}
+
+// Seen implements the Book interface. A `net.Addr` that responds is moved to
+// the tried table.
+func (book *bucketedBook) Seen(addr net.Addr) error {
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	if err := book.Book.Seen(addr); err != nil {
+		return err
+	}
+	return book.promote(addr)
+}
+
+// Sent implements the Book interface. A `net.Addr` that a Message is sent to
+// is moved to the tried table.
+func (book *bucketedBook) Sent(addr net.Addr) error {
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	if err := book.Book.Sent(addr); err != nil {
+		return err
+	}
+	return book.promote(addr)
+}
+
+// Failed implements the Book interface.
+func (book *bucketedBook) Failed(addr net.Addr) error {
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	if err := book.Book.Failed(addr); err != nil {
+		return err
+	}
+	if _, ok := book.Book.Record(addr); !ok {
+		book.clear(addr)
+	}
+	return nil
+}
+
+// Expire implements the Book interface.
+func (book *bucketedBook) Expire(lastSeen time.Time) error {
+	book.mu.Lock()
+	defer book.mu.Unlock()
+
+	if err := book.Book.Expire(lastSeen); err != nil {
+		return err
+	}
+	for _, table := range []*table{book.tried, book.newTable} {
+		expired := make([]net.Addr, 0)
+		for _, addr := range table.addrs() {
+			if _, ok := book.Book.Record(addr); !ok {
+				expired = append(expired, addr)
+			}
+		}
+		for _, addr := range expired {
+			book.clear(addr)
+		}
+	}
+	return nil
+}
+
+// promote a `net.Addr` from the new table to the tried table. It must be
+// called while holding the lock.
+func (book *bucketedBook) promote(addr net.Addr) error {
+	location, ok := book.locations[addr.String()]
+	if !ok || location.tried {
+		return nil
+	}
+	book.clear(addr)
+	return book.insertTried(addr)
+}
+
+// insertTried inserts a `net.Addr` into the tried table. A `net.Addr` that is
+// already at its position is moved back to the new table, or removed if there
+// is no space for it in the new table. It must be called while holding the
+// lock.
+func (book *bucketedBook) insertTried(addr net.Addr) error {
+	bucket, position := book.triedPosition(addr.String())
+	if occupant, ok := book.tried.get(bucket, position); ok {
+		book.clear(occupant)
+		record, _ := book.Book.Record(occupant)
+		ok, err := book.insertNew(occupant, record.Source)
+		if err != nil {
+			return err
+		}
+		if !ok && !record.Seed {
+			if err := book.Book.RemoveAddr(occupant); err != nil {
+				return err
+			}
+		}
+	}
+	book.tried.set(bucket, position, addr)
+	book.locations[addr.String()] = location{true, bucket, position}
+	return nil
+}
+
+// insertNew inserts a `net.Addr`, that is already in the underlying Book, into
+// the new table. It returns false if there is no space for it. It must be
+// called while holding the lock.
+func (book *bucketedBook) insertNew(addr net.Addr, source string) (bool, error) {
+	if source == "" {
+		source = addr.String()
+	}
+	bucket, position := book.newPosition(addr.String(), source)
+	ok, err := book.free(book.newTable, bucket, position)
+	if err != nil || !ok {
+		return false, err
+	}
+	book.newTable.set(bucket, position, addr)
+	book.locations[addr.String()] = location{false, bucket, position}
+	return true, nil
+}
+
+// insertSeed inserts a seed into the underlying Book, and into the new table if
+// it is not already in a table. A `net.Addr` at its position is removed, unless
+// it is also a seed, in which case the seed is only kept in the underlying
+// Book. It must be called while holding the lock.
+func (book *bucketedBook) insertSeed(addr net.Addr) error {
+	if _, ok := book.Book.Record(addr); !ok {
+		if err := book.Book.InsertAddr(addr); err != nil {
+			return err
+		}
+	}
+	if _, ok := book.locations[addr.String()]; ok {
+		return nil
+	}
+
+	bucket, position := book.newPosition(addr.String(), addr.String())
+	if occupant, ok := book.newTable.get(bucket, position); ok {
+		record, _ := book.Book.Record(occupant)
+		if record.Seed {
+			return nil
+		}
+		if err := book.remove(occupant); err != nil {
+			return err
+		}
+	}
+	book.newTable.set(bucket, position, addr)
+	book.locations[addr.String()] = location{false, bucket, position}
+	return nil
+}
+
+// free a position in a table. A `net.Addr` at the position is only removed if
+// it has failed to respond, and is not a seed. It returns false if the
+// position is still taken. It must be called while holding the lock.
+func (book *bucketedBook) free(table *table, bucket, position int) (bool, error) {
+	occupant, ok := table.get(bucket, position)
+	if !ok {
+		return true, nil
+	}
+	record, _ := book.Book.Record(occupant)
+	if record.Failures == 0 || record.Seed {
+		return false, nil
+	}
+	return true, book.remove(occupant)
+}
+
+// remove a `net.Addr` from the underlying Book and from its table. It must be
+// called while holding the lock.
+func (book *bucketedBook) remove(addr net.Addr) error {
+	if err := book.Book.RemoveAddr(addr); err != nil {
+		return err
+	}
+	book.clear(addr)
+	return nil
+}
+
+// clear the position of a `net.Addr` in its table. It must be called while
+// holding the lock.
+func (book *bucketedBook) clear(addr net.Addr) {
+	location, ok := book.locations[addr.String()]
+	if !ok {
+		return
+	}
+	if location.tried {
+		book.tried.clear(location.bucket, location.position)
+	} else {
+		book.newTable.clear(location.bucket, location.position)
+	}
+	delete(book.locations, addr.String())
+}
+
+func (book *bucketedBook) newPosition(addr, source string) (int, int) {
+	sourceGroup := group(source)
+	i := book.hash([]byte("new"), group(addr), sourceGroup) % NewBucketsPerSourceGroup
+	bucket := int(book.hash([]byte("new bucket"), sourceGroup, uint64Bytes(i)) % NewBucketCount)
+	position := int(book.hash([]byte("new position"), uint64Bytes(uint64(bucket)), []byte(addr)) % BucketSize)
+	return bucket, position
+}
+
+func (book *bucketedBook) triedPosition(addr string) (int, int) {
+	i := book.hash([]byte("tried"), []byte(addr)) % TriedBucketsPerGroup
+	bucket := int(book.hash([]byte("tried bucket"), group(addr), uint64Bytes(i)) % TriedBucketCount)
+	position := int(book.hash([]byte("tried position"), uint64Bytes(uint64(bucket)), []byte(addr)) % BucketSize)
+	return bucket, position
+}
+
+// hash the parts, salted with the key of the Book. Every part is prefixed with
+// its length so that different parts cannot produce the same input.
+func (book *bucketedBook) hash(parts ...[]byte) uint64 {
+	hash := sha256.New()
+	hash.Write(book.key)
+	for _, part := range parts {
+		hash.Write(uint64Bytes(uint64(len(part))))
+		hash.Write(part)
+	}
+	return binary.BigEndian.Uint64(hash.Sum(nil))
+}
+
+// group returns the network group of a `net.Addr`. It is the /16 of an IPv4
+// address, the /32 of an IPv6 address, and the host of anything else.
+func group(addr string) []byte {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return append([]byte{0}, host...)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return append([]byte{4}, ip4[:2]...)
+	}
+	return append([]byte{6}, ip[:4]...)
+}
+
+// responded returns true if the `net.Addr` of a Record has ever responded.
+func responded(record Record) bool {
+	return !record.LastSent.IsZero() || record.LastSeen.After(record.FirstSeen)
+}
+
+func uint64Bytes(n uint64) []byte {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, n)
+	return data
+}
+
+// A table of buckets. Only buckets that are not empty are stored.
+type table struct {
+	buckets       map[int]map[int]net.Addr
+	nonEmpty      []int
+	nonEmptyIndex map[int]int
+	size          int
+}
+
+func newTable() *table {
+	return &table{
+		buckets:       map[int]map[int]net.Addr{},
+		nonEmpty:      []int{},
+		nonEmptyIndex: map[int]int{},
+	}
+}
+
+func (table *table) get(bucket, position int) (net.Addr, bool) {
+	addr, ok := table.buckets[bucket][position]
+	return addr, ok
+}
+
+func (table *table) set(bucket, position int, addr net.Addr) {
+	if _, ok := table.buckets[bucket]; !ok {
+		table.buckets[bucket] = map[int]net.Addr{}
+		table.nonEmptyIndex[bucket] = len(table.nonEmpty)
+		table.nonEmpty = append(table.nonEmpty, bucket)
+	}
+	if _, ok := table.buckets[bucket][position]; !ok {
+		table.size++
+	}
+	table.buckets[bucket][position] = addr
+}
+
+func (table *table) clear(bucket, position int) {
+	if _, ok := table.buckets[bucket][position]; !ok {
+		return
+	}
+	table.size--
+	delete(table.buckets[bucket], position)
+	if len(table.buckets[bucket]) > 0 {
+		return
+	}
+	delete(table.buckets, bucket)
+	i := table.nonEmptyIndex[bucket]
+	last := table.nonEmpty[len(table.nonEmpty)-1]
+	table.nonEmpty[i] = last
+	table.nonEmptyIndex[last] = i
+	table.nonEmpty = table.nonEmpty[:len(table.nonEmpty)-1]
+	delete(table.nonEmptyIndex, bucket)
+}
+
+// random returns a `net.Addr` from a random bucket. The table must not be
+// empty.
+func (table *table) random(rand *rand.Rand) net.Addr {
+	bucket := table.nonEmpty[rand.Intn(len(table.nonEmpty))]
+	positions := table.positions(bucket)
+	return table.buckets[bucket][positions[rand.Intn(len(positions))]]
+}
+
+// addrs returns every `net.Addr` in the table. They are ordered by bucket and
+// position, instead of by the order in which maps are iterated, so that the
+// samples of a Book only depend on its random source.
+func (table *table) addrs() []net.Addr {
+	addrs := make([]net.Addr, 0, table.size)
+	for _, bucket := range table.nonEmpty {
+		for _, position := range table.positions(bucket) {
+			addrs = append(addrs, table.buckets[bucket][position])
+		}
+	}
+	return addrs
+}
+
+// positions returns the positions that are taken in a bucket, in order.
+func (table *table) positions(bucket int) []int {
+	positions := make([]int, 0, len(table.buckets[bucket]))
+	for position := range table.buckets[bucket] {
+		positions = append(positions, position)
+	}
+	sort.Ints(positions)
+	return positions
+}
@@ -0,0 +1,169 @@
+package addr_test
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/addr"
+
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+var _ = Describe("Bucketed book", func() {
+
+	// Addresses and samples are drawn from seeded sources, so that the shares
+	// that are compared against thresholds do not change between runs
+	var r *rand.Rand
+
+	BeforeEach(func() {
+		r = rand.New(rand.NewSource(1))
+	})
+
+	newBook := func(addrs Addrs) SourcedBook {
+		book, err := NewBucketedBookWithSource(addrs, []byte("key"), rand.NewSource(1))
+		Expect(err).ShouldNot(HaveOccurred())
+		return book
+	}
+
+	ipAddr := func(a, b, c, d int) net.Addr {
+		addr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("%v.%v.%v.%v:18514", a, b, c, d))
+		Expect(err).ShouldNot(HaveOccurred())
+		return addr
+	}
+
+	// randomHonestAddr returns an address in a random /16 group that is
+	// outside of the 10.0.0.0/8 range used by attackers.
+	randomHonestAddr := func() net.Addr {
+		return ipAddr(11+r.Intn(200), r.Intn(256), r.Intn(256), r.Intn(256))
+	}
+
+	randomAttackerAddr := func() net.Addr {
+		return ipAddr(10, r.Intn(256), r.Intn(256), r.Intn(256))
+	}
+
+	// attackerShare returns the share of sampled addresses that belong to
+	// the attacker.
+	attackerShare := func(book Book, α, samples int) float64 {
+		attacker, total := 0, 0
+		for i := 0; i < samples; i++ {
+			addrs, err := book.Addrs(α)
+			Expect(err).ShouldNot(HaveOccurred())
+			for _, addr := range addrs {
+				if addr.(*net.TCPAddr).IP.To4()[0] == 10 {
+					attacker++
+				}
+				total++
+			}
+		}
+		return float64(attacker) / float64(total)
+	}
+
+	Context("when an attacker floods the book", func() {
+
+		It("should cap the number of addresses from one group", func() {
+			book := newBook(testutils.NewMockAddrs())
+			source := ipAddr(10, 0, 0, 1)
+			for i := 0; i < 10000; i++ {
+				Expect(book.InsertAddrFrom(ipAddr(10, 1, r.Intn(256), r.Intn(256)), source)).ShouldNot(HaveOccurred())
+			}
+
+			addrs, err := book.Addrs(10000)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(addrs)).Should(BeNumerically("<=", BucketSize))
+		})
+
+		It("should bound the share of selected peers that belong to the attacker", func() {
+			addrs := testutils.NewMockAddrs()
+			book := newBook(addrs)
+			unbucketed, err := NewBookWithSource(testutils.NewMockAddrs(), rand.NewSource(1))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			for i := 0; i < 1000; i++ {
+				addr := randomHonestAddr()
+				Expect(book.InsertAddr(addr)).ShouldNot(HaveOccurred())
+				Expect(unbucketed.InsertAddr(addr)).ShouldNot(HaveOccurred())
+			}
+			sources := []net.Addr{ipAddr(10, 0, 0, 1), ipAddr(10, 1, 0, 1), ipAddr(10, 2, 0, 1), ipAddr(10, 3, 0, 1)}
+			for i := 0; i < 50000; i++ {
+				addr := randomAttackerAddr()
+				Expect(book.InsertAddrFrom(addr, sources[i%len(sources)])).ShouldNot(HaveOccurred())
+				Expect(unbucketed.InsertAddr(addr)).ShouldNot(HaveOccurred())
+			}
+
+			// The attacker can only reach the new buckets of its source
+			// groups, which is a quarter of all new buckets
+			Expect(attackerShare(unbucketed, 8, 1000)).Should(BeNumerically(">", 0.9))
+			Expect(attackerShare(book, 8, 1000)).Should(BeNumerically("<", 0.4))
+
+			// Addresses that were flooded into the book are not kept in the
+			// store either
+			stored, err := addrs.Addrs()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(stored)).Should(BeNumerically("<", 1000+len(sources)*NewBucketsPerSourceGroup*BucketSize))
+		})
+
+		It("should prefer peers that have responded", func() {
+			book := newBook(testutils.NewMockAddrs())
+
+			for i := 0; i < 100; i++ {
+				addr := randomHonestAddr()
+				Expect(book.InsertAddr(addr)).ShouldNot(HaveOccurred())
+				Expect(book.Seen(addr)).ShouldNot(HaveOccurred())
+			}
+			source := ipAddr(10, 0, 0, 1)
+			for i := 0; i < 50000; i++ {
+				Expect(book.InsertAddrFrom(randomAttackerAddr(), source)).ShouldNot(HaveOccurred())
+			}
+
+			Expect(attackerShare(book, 8, 1000)).Should(BeNumerically("<", 0.6))
+		})
+	})
+
+	Context("when loading from a store", func() {
+
+		It("should keep addresses and their sources", func() {
+			addrs := testutils.NewMockAddrs()
+			key := []byte("key")
+			book, err := NewBucketedBook(addrs, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			source := ipAddr(1, 2, 3, 4)
+			tried := ipAddr(5, 6, 7, 8)
+			learned := ipAddr(9, 10, 11, 12)
+			Expect(book.InsertAddr(tried)).ShouldNot(HaveOccurred())
+			Expect(book.Seen(tried)).ShouldNot(HaveOccurred())
+			Expect(book.InsertAddrFrom(learned, source)).ShouldNot(HaveOccurred())
+
+			book, err = NewBucketedBook(addrs, key)
+			Expect(err).ShouldNot(HaveOccurred())
+			sample, err := book.Addrs(2)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(sample).Should(ConsistOf(tried, learned))
+			record, ok := book.Record(learned)
+			Expect(ok).Should(BeTrue())
+			Expect(record.Source).Should(Equal(source.String()))
+		})
+	})
+
+	Context("when removing addresses", func() {
+
+		It("should free their position for other addresses", func() {
+			book := newBook(testutils.NewMockAddrs())
+			addr := ipAddr(1, 2, 3, 4)
+			Expect(book.InsertAddr(addr)).ShouldNot(HaveOccurred())
+			for i := 0; i < MaxFailures; i++ {
+				Expect(book.Failed(addr)).ShouldNot(HaveOccurred())
+			}
+
+			addrs, err := book.Addrs(1)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrs).Should(BeEmpty())
+			Expect(book.InsertAddr(addr)).ShouldNot(HaveOccurred())
+			addrs, err = book.Addrs(1)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrs).Should(Equal([]net.Addr{addr}))
+		})
+	})
+})
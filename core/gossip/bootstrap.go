@@ -0,0 +1,191 @@
+package gossip
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/core/logging"
+	"github.com/republicprotocol/co-go"
+)
+
+// ErrNoSeeds is returned when bootstrapping and none of the seeds respond.
+var ErrNoSeeds = errors.New("cannot contact any seed")
+
+// A Bootstrapper fills an `addr.Book` with the peers that are known to a list
+// of seed `net.Addr`. Seeds are inserted into the `addr.Book` as seeds, so
+// that they are never evicted or expired.
+type Bootstrapper interface {
+
+	// Bootstrap requests peers from every seed, and inserts them into the
+	// `addr.Book`. Seeds that have responded recently are skipped, so that
+	// they are not overloaded. It returns ErrNoSeeds if seeds were
+	// contacted, but none of them responded.
+	Bootstrap(ctx context.Context) error
+
+	// Refill calls Bootstrap, but only if the `addr.Book` has fewer than the
+	// minimum number of `net.Addr`.
+	Refill(ctx context.Context) error
+}
+
+type bootstrapper struct {
+	self     net.Addr
+	addrBook addr.Book
+	client   Client
+	seeds    []net.Addr
+	minAddrs int
+	interval time.Duration
+	logger   logging.Logger
+
+	contactedMu *sync.Mutex
+	contacted   map[string]time.Time
+}
+
+// NewBootstrapper returns a Bootstrapper for the node at the `self` address,
+// that fills the `addrBook` using the `client` to request peers from the
+// `seeds`. The `self` address is never inserted into the `addrBook`, and can be
+// nil if it is not known. The `addrBook` is refilled when it has fewer than
+// `minAddrs`, and each seed that responds is contacted at most once per
+// `interval`. The
+// `logger` can be nil, in which case the default `logging.Logger` is used.
+func NewBootstrapper(self net.Addr, addrBook addr.Book, client Client, seeds []net.Addr, minAddrs int, interval time.Duration, logger logging.Logger) Bootstrapper {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	return &bootstrapper{
+		self:     self,
+		addrBook: addrBook,
+		client:   client,
+		seeds:    seeds,
+		minAddrs: minAddrs,
+		interval: interval,
+		logger:   logger,
+
+		contactedMu: new(sync.Mutex),
+		contacted:   map[string]time.Time{},
+	}
+}
+
+// RunBootstrap calls `Bootstrap` once, and then calls `Refill` once every
+// period until the context is done. Errors are logged by the `logger`, and do
+// not stop future rounds. The `logger` can be nil, in which case the default
+// `logging.Logger` is used.
+func RunBootstrap(ctx context.Context, bootstrapper Bootstrapper, period time.Duration, logger logging.Logger) {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	if err := bootstrapper.Bootstrap(ctx); err != nil {
+		logger.Error("cannot bootstrap", logging.Err(err))
+	}
+
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := bootstrapper.Refill(ctx); err != nil {
+			logger.Error("cannot refill address book", logging.Err(err))
+		}
+	}
+}
+
+// Bootstrap implements the Bootstrapper interface.
+func (bootstrapper *bootstrapper) Bootstrap(ctx context.Context) error {
+	seeds := make([]net.Addr, 0, len(bootstrapper.seeds))
+	for _, seed := range bootstrapper.seeds {
+		// A seed does not bootstrap from itself
+		if isSelf(bootstrapper.self, seed) {
+			continue
+		}
+		if err := bootstrapper.insertSeed(seed); err != nil {
+			return err
+		}
+		if bootstrapper.shouldContact(seed) {
+			seeds = append(seeds, seed)
+		}
+	}
+	if len(seeds) == 0 {
+		return nil
+	}
+
+	errs := make([]error, len(seeds))
+	co.ForAll(seeds, func(i int) {
+		errs[i] = requestPeers(ctx, bootstrapper.addrBook, bootstrapper.client, bootstrapper.logger, bootstrapper.self, seeds[i])
+		if errs[i] != nil {
+			bootstrapper.logger.Debug("cannot bootstrap from seed", logging.Peer(seeds[i]), logging.Err(errs[i]))
+			bootstrapper.forgetContact(seeds[i])
+		}
+	})
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+	}
+	return ErrNoSeeds
+}
+
+// Refill implements the Bootstrapper interface.
+func (bootstrapper *bootstrapper) Refill(ctx context.Context) error {
+	addrs, err := bootstrapper.addrBook.Addrs(bootstrapper.minAddrs)
+	if err != nil {
+		return err
+	}
+	if len(addrs) >= bootstrapper.minAddrs {
+		return nil
+	}
+	return bootstrapper.Bootstrap(ctx)
+}
+
+// insertSeed into the `addr.Book`, and mark it as a seed, if it is not already
+// marked as a seed.
+func (bootstrapper *bootstrapper) insertSeed(seed net.Addr) error {
+	record, ok := bootstrapper.addrBook.Record(seed)
+	if ok && record.Seed {
+		return nil
+	}
+	if !ok {
+		if err := bootstrapper.addrBook.InsertAddr(seed); err != nil {
+			return err
+		}
+		// A Book can drop the seed when it is inserted, in which case the
+		// Record that marks it as a seed inserts it instead
+		if record, ok = bootstrapper.addrBook.Record(seed); !ok {
+			now := time.Now()
+			record = addr.Record{FirstSeen: now, LastSeen: now}
+		}
+	}
+	record.Seed = true
+	return bootstrapper.addrBook.InsertRecord(seed, record)
+}
+
+// shouldContact returns true if the seed has not been contacted within the
+// interval, and records that it is being contacted now. The record stops
+// concurrent bootstraps from contacting the seed at the same time, and is
+// forgotten if the seed does not respond.
+func (bootstrapper *bootstrapper) shouldContact(seed net.Addr) bool {
+	bootstrapper.contactedMu.Lock()
+	defer bootstrapper.contactedMu.Unlock()
+
+	now := time.Now()
+	if contacted, ok := bootstrapper.contacted[seed.String()]; ok && now.Sub(contacted) < bootstrapper.interval {
+		return false
+	}
+	bootstrapper.contacted[seed.String()] = now
+	return true
+}
+
+// forgetContact with the seed, so that a seed that did not respond is
+// contacted again by the next bootstrap.
+func (bootstrapper *bootstrapper) forgetContact(seed net.Addr) {
+	bootstrapper.contactedMu.Lock()
+	defer bootstrapper.contactedMu.Unlock()
+
+	delete(bootstrapper.contacted, seed.String())
+}
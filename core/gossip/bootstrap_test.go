@@ -0,0 +1,185 @@
+package gossip_test
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+// peersClient counts the number of times that peers are requested.
+type peersClient struct {
+	testutils.MockClient
+	requests *int64
+}
+
+func (client peersClient) Peers(ctx context.Context, to net.Addr, n int) ([]net.Addr, error) {
+	atomic.AddInt64(client.requests, 1)
+	return client.MockClient.Peers(ctx, to, n)
+}
+
+var _ = Describe("Bootstrapper", func() {
+
+	// init returns a Book and a seed that is connected to the client, and
+	// knows about the peers.
+	init := func(peers ...net.Addr) (addr.Book, net.Addr, peersClient) {
+		seedBook, err := addr.NewBook(testutils.NewMockAddrs())
+		Expect(err).ShouldNot(HaveOccurred())
+		for _, peer := range peers {
+			Expect(seedBook.InsertAddr(peer)).ShouldNot(HaveOccurred())
+		}
+		client := peersClient{testutils.NewMockClient(), new(int64)}
+		seed := testutils.RandomAddr()
+		client.Connect(seed, NewGossiper(seedBook, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, testutils.NewMockMessages()))
+
+		book, err := addr.NewBook(testutils.NewMockAddrs())
+		Expect(err).ShouldNot(HaveOccurred())
+		return book, seed, client
+	}
+
+	Context("when bootstrapping", func() {
+
+		It("should learn the peers that are known to the seeds", func() {
+			peers := []net.Addr{testutils.RandomAddr(), testutils.RandomAddr()}
+			book, seed, client := init(peers...)
+			bootstrapper := NewBootstrapper(nil, book, client, []net.Addr{seed}, 1, time.Minute, nil)
+
+			Expect(bootstrapper.Bootstrap(context.Background())).ShouldNot(HaveOccurred())
+			known, err := book.Addrs(3)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(known).Should(ConsistOf(seed, peers[0], peers[1]))
+			record, ok := book.Record(seed)
+			Expect(ok).Should(BeTrue())
+			Expect(record.Seed).Should(BeTrue())
+		})
+
+		It("should not learn its own address", func() {
+			self := testutils.RandomAddr()
+			peer := testutils.RandomAddr()
+			book, seed, client := init(self, peer)
+			bootstrapper := NewBootstrapper(self, book, client, []net.Addr{seed, self}, 1, time.Minute, nil)
+
+			Expect(bootstrapper.Bootstrap(context.Background())).ShouldNot(HaveOccurred())
+			known, err := book.Addrs(3)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(known).Should(ConsistOf(seed, peer))
+		})
+
+		It("should not evict seeds that keep failing", func() {
+			book, _, client := init()
+			unreachable := testutils.RandomAddr()
+			bootstrapper := NewBootstrapper(nil, book, client, []net.Addr{unreachable}, 1, 0, nil)
+
+			for i := 0; i < 2*addr.MaxFailures; i++ {
+				Expect(bootstrapper.Bootstrap(context.Background())).Should(Equal(ErrNoSeeds))
+			}
+			Expect(book.Expire(time.Now().Add(time.Hour))).ShouldNot(HaveOccurred())
+			record, ok := book.Record(unreachable)
+			Expect(ok).Should(BeTrue())
+			Expect(record.Failures).Should(Equal(2 * addr.MaxFailures))
+		})
+
+		It("should keep seeds whose position is taken in a bucketed book", func() {
+			// The addresses, the key, and the random sources are fixed, so that
+			// the positions that are taken do not change between runs
+			r := rand.New(rand.NewSource(1))
+			seed := &net.TCPAddr{IP: net.IPv4(12, 0, 0, 1), Port: 18514}
+			peer := &net.TCPAddr{IP: net.IPv4(13, 0, 0, 1), Port: 18514}
+			seedBook, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(seedBook.InsertAddr(peer)).ShouldNot(HaveOccurred())
+			client := testutils.NewMockClient()
+			client.Connect(seed, NewGossiper(seedBook, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, testutils.NewMockMessages()))
+			book, err := addr.NewBucketedBookWithSource(testutils.NewMockAddrs(), []byte("key"), rand.NewSource(1))
+			Expect(err).ShouldNot(HaveOccurred())
+
+			// Addresses from the same group as the seed are stored in the same
+			// bucket as the seed, so flooding that bucket takes the position
+			// of the seed
+			for i := 0; i < 5000; i++ {
+				flooded := &net.TCPAddr{IP: net.IPv4(12, 0, byte(r.Intn(256)), byte(r.Intn(256))), Port: 18514}
+				Expect(book.InsertAddr(flooded)).ShouldNot(HaveOccurred())
+			}
+			Expect(book.InsertAddr(seed)).ShouldNot(HaveOccurred())
+			_, ok := book.Record(seed)
+			Expect(ok).Should(BeFalse())
+			bootstrapper := NewBootstrapper(nil, book, client, []net.Addr{seed}, 1, 0, nil)
+
+			Expect(bootstrapper.Bootstrap(context.Background())).ShouldNot(HaveOccurred())
+			record, ok := book.Record(seed)
+			Expect(ok).Should(BeTrue())
+			Expect(record.Seed).Should(BeTrue())
+			_, ok = book.Record(peer)
+			Expect(ok).Should(BeTrue())
+
+			for i := 0; i < 2*addr.MaxFailures; i++ {
+				Expect(book.Failed(seed)).ShouldNot(HaveOccurred())
+			}
+			known, err := book.Addrs(10000)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(known).Should(ContainElement(seed))
+		})
+
+		It("should not contact a seed more than once per interval", func() {
+			book, seed, client := init(testutils.RandomAddr())
+			bootstrapper := NewBootstrapper(nil, book, client, []net.Addr{seed}, 1, time.Hour, nil)
+
+			for i := 0; i < 3; i++ {
+				Expect(bootstrapper.Bootstrap(context.Background())).ShouldNot(HaveOccurred())
+			}
+			Expect(atomic.LoadInt64(client.requests)).Should(Equal(int64(1)))
+		})
+
+		It("should contact a seed that did not respond again within the interval", func() {
+			peer := testutils.RandomAddr()
+			book, _, client := init()
+			seed := testutils.RandomAddr()
+			bootstrapper := NewBootstrapper(nil, book, client, []net.Addr{seed}, 3, time.Hour, nil)
+
+			Expect(bootstrapper.Bootstrap(context.Background())).Should(Equal(ErrNoSeeds))
+			Expect(bootstrapper.Refill(context.Background())).Should(Equal(ErrNoSeeds))
+			Expect(atomic.LoadInt64(client.requests)).Should(Equal(int64(2)))
+
+			// Once the seed responds, it is not contacted again within the
+			// interval
+			seedBook, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(seedBook.InsertAddr(peer)).ShouldNot(HaveOccurred())
+			client.Connect(seed, NewGossiper(seedBook, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, testutils.NewMockMessages()))
+			Expect(bootstrapper.Refill(context.Background())).ShouldNot(HaveOccurred())
+			_, ok := book.Record(peer)
+			Expect(ok).Should(BeTrue())
+			Expect(bootstrapper.Refill(context.Background())).ShouldNot(HaveOccurred())
+			Expect(atomic.LoadInt64(client.requests)).Should(Equal(int64(3)))
+		})
+	})
+
+	Context("when refilling", func() {
+
+		It("should only contact the seeds when there are too few addresses", func() {
+			peers := []net.Addr{testutils.RandomAddr(), testutils.RandomAddr()}
+			book, seed, client := init(peers...)
+			bootstrapper := NewBootstrapper(nil, book, client, []net.Addr{seed}, 3, 0, nil)
+
+			Expect(bootstrapper.Refill(context.Background())).ShouldNot(HaveOccurred())
+			Expect(atomic.LoadInt64(client.requests)).Should(Equal(int64(1)))
+			Expect(book.Addrs(3)).Should(HaveLen(3))
+
+			Expect(bootstrapper.Refill(context.Background())).ShouldNot(HaveOccurred())
+			Expect(atomic.LoadInt64(client.requests)).Should(Equal(int64(1)))
+
+			Expect(book.RemoveAddr(peers[0])).ShouldNot(HaveOccurred())
+			Expect(bootstrapper.Refill(context.Background())).ShouldNot(HaveOccurred())
+			Expect(atomic.LoadInt64(client.requests)).Should(Equal(int64(2)))
+			Expect(book.Addrs(3)).Should(HaveLen(3))
+		})
+	})
+})
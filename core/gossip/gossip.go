@@ -1,13 +1,13 @@
 package gossip
 
 import (
+	"bytes"
 	"context"
-	"log"
+	"errors"
 	"net"
-	"time"
 
 	"github.com/republicprotocol/babble-go/core/addr"
-	"github.com/republicprotocol/co-go"
+	"github.com/republicprotocol/babble-go/core/logging"
 )
 
 // An Observer is notified whenever a new Message, or an update to an existing
@@ -16,23 +16,92 @@ type Observer interface {
 	Notify(message Message) error
 }
 
+// A Scheme identifies the signature scheme that produced a signature. It is
+// carried alongside the signature of every Message, so that a node can accept
+// signatures from several schemes at once. The zero Scheme is not used by any
+// signature scheme.
+type Scheme uint8
+
 // A Signer can consume bytes and produce a signature for those bytes. This
-// signature can be used by a Verifier to extract the signatory.
+// signature can be used by a Verifier to extract the signatory. The bytes
+// signed for a Message are always the `Message.Payload`.
 type Signer interface {
+
+	// Scheme returns the signature Scheme used by the Signer.
+	Scheme() Scheme
+
+	// Sign the data and return the signature.
 	Sign(data []byte) ([]byte, error)
 }
 
+// ErrNotOwner is returned when a Message updates a `Key` that is owned by a
+// different Signatory than the one that signed the Message.
+var ErrNotOwner = errors.New("signatory does not own the message key")
+
+// A Signatory identifies the signer of some bytes. Its encoding depends on the
+// Verifier that extracted it, but all signatures produced by the same signer
+// must be extracted to equal Signatories.
+type Signatory []byte
+
 // A Verifier can consume bytes and a signature for those bytes, and extract
-// the signatory.
+// the signatory. The Scheme of the signature is used to pick the signature
+// scheme that verifies it. A Verifier must return an error for any Scheme that
+// it does not support.
 type Verifier interface {
-	Verify(data []byte, signature []byte) error
+	Verify(scheme Scheme, data []byte, signature []byte) (Signatory, error)
+}
+
+// Delegations are used to find the owner on whose behalf a Signatory signs.
+// The first Message received for a `Key` claims that `Key` for the owner of
+// its Signatory. Afterwards, a Message with a higher `Nonce` is only accepted
+// if its Signatory has the same owner.
+type Delegations interface {
+
+	// Owner returns the Signatory that has delegated to the `signatory`. It
+	// returns the `signatory` itself if no other Signatory has delegated to
+	// it.
+	Owner(signatory Signatory) (Signatory, error)
+}
+
+// A RejectedError is returned by a Client when a remote Server responded to a
+// request with an error. The remote Server is alive, so it is not recorded as
+// having failed.
+type RejectedError struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (err RejectedError) Error() string {
+	return err.Err.Error()
+}
+
+// IsRejected returns true if the error is a RejectedError.
+func IsRejected(err error) bool {
+	_, ok := err.(RejectedError)
+	return ok
 }
 
 // A Client is used to send Store to a remote Server.
 type Client interface {
 
-	// Send a Message to the a remote `net.Addr`.
+	// Send a Message to the a remote `net.Addr`. It returns a RejectedError
+	// if the remote Server responded, but did not accept the Message.
 	Send(ctx context.Context, to net.Addr, message Message) error
+
+	// Sync sends the Digests of all local Messages in the subtree of the node
+	// at the path to a remote `net.Addr`. It returns the Messages in the same
+	// subtree that the remote Server has and that are newer, or missing,
+	// locally. It also returns the Digests that the remote Server wants to
+	// have pushed to it. The empty path includes all Messages.
+	Sync(ctx context.Context, to net.Addr, path []byte, digests []Digest) ([]Message, []Digest, error)
+
+	// Hashes returns the hashes of the children of the node at the path in
+	// the Merkle tree of a remote `net.Addr`.
+	Hashes(ctx context.Context, to net.Addr, path []byte) ([][]byte, error)
+
+	// Peers returns a random sample of at most n `net.Addr` that are known
+	// to a remote `net.Addr`.
+	Peers(ctx context.Context, to net.Addr, n int) ([]net.Addr, error)
 }
 
 // A Server receives Store.
@@ -41,48 +110,155 @@ type Server interface {
 	// Receive is called to notify the Server that a Message has been received
 	// from a remote Client.
 	Receive(ctx context.Context, message Message) error
+
+	// Sync is called when a remote Client starts a round of anti-entropy
+	// with the Digests of all of its Messages in the subtree of the node at
+	// the path. The Server returns all of its Messages in the same subtree
+	// that are newer than, or missing from, the Digests. It also returns the
+	// Digests for which the Client has a newer Message.
+	Sync(ctx context.Context, path []byte, digests []Digest) ([]Message, []Digest, error)
+
+	// Hashes is called when a remote Client compares its Merkle tree with
+	// the Merkle tree of the Server. It returns ErrNoTree if the Messages of
+	// the Server are not stored in a Tree.
+	Hashes(ctx context.Context, path []byte) ([][]byte, error)
+
+	// Peers is called when a remote Client exchanges peers with the Server.
+	// It returns a random sample of at most n, and never more than MaxPeers,
+	// `net.Addr` from the `addr.Book` of the Server.
+	Peers(ctx context.Context, n int) ([]net.Addr, error)
 }
 
 // Gossiper is a participant in the gossip network. It can receive message and
 // broadcast new message to the network.
 type Gossiper interface {
 	Server
+
+	// Broadcast signs a Message and enqueues it in the Outbound of the
+	// Gossiper, to be sent to α peers. It returns once the Message has been
+	// enqueued for every peer. If the Outbound returns an error, such as
+	// ErrQueueFull, the Message can already have been enqueued for some of
+	// the peers, and it is still sent to them.
 	Broadcast(ctx context.Context, message Message) error
+
+	// BroadcastSync signs and sends a Message to α peers, like Broadcast, but
+	// waits for every send to finish, or for the context to be done. It
+	// returns a Report of which peers acknowledged the Message, and which
+	// failed and why. Messages are sent through the Outbound of the
+	// Gossiper, so that they are bounded in the same way as broadcasts.
+	BroadcastSync(ctx context.Context, message Message) (Report, error)
+
+	// BroadcastQuorum signs and sends a Message to peers until k distinct
+	// peers have acknowledged it, which means that they have stored it. It
+	// keeps sampling more peers from the `addr.Book`, and retries peers that
+	// failed once every other peer has been tried. It returns a Report of
+	// every send, and ErrNoQuorum if the context is done before k peers have
+	// acknowledged the Message.
+	BroadcastQuorum(ctx context.Context, message Message, k int) (Report, error)
+
+	// Synchronise runs one round of anti-entropy with a random `net.Addr`
+	// from the `addr.Book`. Messages that differ between the two nodes are
+	// pulled from, and pushed to, the remote node. If the Messages of the
+	// Gossiper are stored in a Tree, only the subtrees that differ between
+	// the Merkle trees of the two nodes are synchronised.
+	Synchronise(ctx context.Context) error
+
+	// ExchangePeers runs one round of peer exchange with a random `net.Addr`
+	// from the `addr.Book`. The `net.Addr` that are known to the remote node
+	// are inserted into the `addr.Book`.
+	ExchangePeers(ctx context.Context) error
+
+	// Start the Gossiper. Its Outbound is run until the Gossiper is stopped.
+	// A Gossiper that has not been started is started by its first
+	// broadcast.
+	Start() error
+
+	// Go runs a background task, such as RunAntiEntropy, until the Gossiper
+	// is stopped. The context that is given to the task is done when the
+	// Gossiper is stopped.
+	Go(task func(ctx context.Context))
+
+	// Stop the Gossiper. Broadcasts are rejected with ErrStopped as soon as
+	// Stop is called. Stop waits for pending sends to finish until the
+	// context is done, then cancels any sends that are still in flight,
+	// stops all background tasks, and waits for them to return. It returns
+	// the error of the context if pending sends were cancelled.
+	Stop(ctx context.Context) error
 }
 
 type gossiper struct {
 	addrBook addr.Book
 	α        int
 
-	signer   Signer
-	verifier Verifier
-	observer Observer
-	client   Client
-	messages Messages
+	signer      Signer
+	verifier    Verifier
+	delegations Delegations
+	resolver    Resolver
+	selector    PeerSelector
+	observer    Observer
+	client      Client
+	outbound    Outbound
+	messages    Messages
+	logger      logging.Logger
+	self        net.Addr
+
+	lifecycle *lifecycle
 }
 
-// NewGossiper returns a new gosspier.
-func NewGossiper(addrBook addr.Book, α int, signer Signer, verifier Verifier, observer Observer, client Client, messages Messages) Gossiper {
+// NewGossiper returns a new gosspier. The `delegations` can be nil, in which
+// case every Signatory is its own owner. The `resolver` can be nil, in which
+// case conflicts are resolved by the Resolver returned by NewHashResolver.
+// Other dependencies are optional, and are configured by the `opts`.
+func NewGossiper(addrBook addr.Book, α int, signer Signer, verifier Verifier, delegations Delegations, resolver Resolver, observer Observer, client Client, messages Messages, opts ...Option) Gossiper {
+	if resolver == nil {
+		resolver = NewHashResolver()
+	}
+	options := newOptions(opts)
+	if options.outbound == nil {
+		options.outbound = NewOutbound(addrBook, client, DefaultQueueSize, DefaultWorkers, DefaultPerPeer, DefaultTimeout, Block, options.logger)
+	}
 	return &gossiper{
 		addrBook: addrBook,
 		α:        α,
 
-		signer:   signer,
-		verifier: verifier,
-		observer: observer,
-		client:   client,
-		messages: messages,
+		signer:      signer,
+		verifier:    verifier,
+		delegations: delegations,
+		resolver:    resolver,
+		selector:    options.selector,
+		observer:    observer,
+		client:      client,
+		outbound:    options.outbound,
+		messages:    messages,
+		logger:      options.logger,
+		self:        options.self,
+
+		lifecycle: newLifecycle(),
 	}
 }
 
 // Broadcast implements the Gossiper interface.
 func (gossiper *gossiper) Broadcast(ctx context.Context, message Message) error {
-	return gossiper.broadcast(ctx, message, true)
+	if err := gossiper.broadcast(ctx, message, true, nil); err != nil {
+		gossiper.logger.Error("cannot broadcast message", logging.Key(message.Key), logging.Nonce(message.Nonce), logging.Err(err))
+		return err
+	}
+	return nil
 }
 
 // Receive implements the Gossiper interface.
 func (gossiper *gossiper) Receive(ctx context.Context, message Message) error {
-	if err := gossiper.verifier.Verify(message.Value, message.Signature); err != nil {
+	if err := gossiper.receive(ctx, message, true); err != nil {
+		sender, _ := SenderFromContext(ctx)
+		gossiper.logger.Warn("cannot receive message", logging.Peer(sender), logging.Key(message.Key), logging.Nonce(message.Nonce), logging.Err(err))
+		return err
+	}
+	return nil
+}
+
+func (gossiper *gossiper) receive(ctx context.Context, message Message, forward bool) error {
+	signatory, err := gossiper.verifier.Verify(message.Scheme, message.Payload(), message.Signature)
+	if err != nil {
 		return err
 	}
 
@@ -90,10 +266,24 @@ func (gossiper *gossiper) Receive(ctx context.Context, message Message) error {
 	if err != nil {
 		return err
 	}
-	if previousMessage.Nonce >= message.Nonce {
+	if !IsNewer(gossiper.resolver, previousMessage, message) {
 		return nil
 	}
-	if err := gossiper.messages.InsertMessage(message); err != nil {
+	owner, err := gossiper.owner(signatory)
+	if err != nil {
+		return err
+	}
+	if err := gossiper.verifyOwner(previousMessage, owner); err != nil {
+		return err
+	}
+	message.Owner = owner
+
+	// Another Message with the same key can be inserted after the previous
+	// Message was read, so the Message is only inserted if it is still newer.
+	// Any Message that was inserted in the meantime was also verified against
+	// the previous Message, so it has the same owner.
+	ok, err := gossiper.messages.InsertMessageIfNewer(message, gossiper.resolver)
+	if err != nil || !ok {
 		return err
 	}
 
@@ -103,33 +293,109 @@ func (gossiper *gossiper) Receive(ctx context.Context, message Message) error {
 		}
 	}
 
-	return gossiper.broadcast(ctx, message, false)
+	if !forward {
+		return nil
+	}
+
+	// The sender already has the Message, so it is not forwarded back
+	var exclude []net.Addr
+	if sender, ok := SenderFromContext(ctx); ok {
+		exclude = []net.Addr{sender}
+	}
+	return gossiper.broadcast(ctx, message, false, exclude)
 }
 
-func (gossiper *gossiper) broadcast(ctx context.Context, message Message, sign bool) error {
-	if sign {
-		signature, err := gossiper.signer.Sign(message.Value)
+// verifyOwner returns ErrNotOwner if the `owner` is not the owner of the
+// `previousMessage`. A `previousMessage` with a zero nonce has not been claimed
+// by any owner. A `previousMessage` that was stored without its owner has its
+// owner resolved from its signature instead.
+func (gossiper *gossiper) verifyOwner(previousMessage Message, owner Signatory) error {
+	if previousMessage.Nonce == 0 {
+		return nil
+	}
+	previousOwner := previousMessage.Owner
+	if previousOwner == nil {
+		previousSignatory, err := gossiper.verifier.Verify(previousMessage.Scheme, previousMessage.Payload(), previousMessage.Signature)
 		if err != nil {
 			return err
 		}
-		message.Signature = signature
+		if previousOwner, err = gossiper.owner(previousSignatory); err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(owner, previousOwner) {
+		return ErrNotOwner
 	}
+	return nil
+}
 
-	addrs, err := gossiper.addrBook.Addrs(gossiper.α)
+func (gossiper *gossiper) owner(signatory Signatory) (Signatory, error) {
+	if gossiper.delegations == nil {
+		return signatory, nil
+	}
+	return gossiper.delegations.Owner(signatory)
+}
+
+func (gossiper *gossiper) broadcast(ctx context.Context, message Message, sign bool, exclude []net.Addr) error {
+	if sign {
+		var err error
+		if message, err = gossiper.sign(message); err != nil {
+			return err
+		}
+	}
+
+	addrs, err := gossiper.selector.SelectPeers(gossiper.addrBook, gossiper.α, message, exclude)
 	if err != nil {
 		return err
 	}
 
-	go co.ForAll(addrs, func(i int) {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-		defer cancel()
+	if err := gossiper.Start(); err != nil {
+		return err
+	}
 
-		err := gossiper.client.Send(ctx, addrs[i], message)
-		if err != nil {
-			// TODO : config the logger
-			log.Printf("[error] cannot send messge to %v = %v", addrs[i].String(), err)
+	// The Message is enqueued within the lifetime of the Gossiper, so that
+	// Stop waits for it to be enqueued before it drains the Outbound
+	_, done, err := gossiper.lifecycle.begin()
+	if err != nil {
+		return err
+	}
+	defer done()
+	for _, addr := range addrs {
+		if err := gossiper.outbound.Enqueue(ctx, addr, message); err != nil {
+			return err
 		}
-	})
+	}
+	return nil
+}
 
+func (gossiper *gossiper) send(ctx context.Context, to net.Addr, message Message) error {
+	return send(ctx, gossiper.addrBook, gossiper.client, gossiper.logger, to, message)
+}
+
+// send a Message to a `net.Addr`, and record in the `addr.Book` whether or not
+// the `net.Addr` responded, so that `net.Addr` that keep failing are evicted.
+// A send that fails because the context is done is not recorded, since it does
+// not say anything about the `net.Addr`, and neither is a failed retry.
+func send(ctx context.Context, addrBook addr.Book, client Client, logger logging.Logger, to net.Addr, message Message) error {
+	if err := client.Send(ctx, to, message); err != nil {
+		if IsRejected(err) {
+			// The peer responded, so it is alive even though it did not
+			// accept the Message
+			if err := addrBook.Seen(to); err != nil {
+				logger.Error("cannot record response", logging.Peer(to), logging.Err(err))
+			}
+			return err
+		}
+		if ctx.Err() != nil || isRetry(ctx) {
+			return err
+		}
+		if err := addrBook.Failed(to); err != nil {
+			logger.Error("cannot record failure", logging.Peer(to), logging.Err(err))
+		}
+		return err
+	}
+	if err := addrBook.Sent(to); err != nil {
+		logger.Error("cannot record response", logging.Peer(to), logging.Err(err))
+	}
 	return nil
 }
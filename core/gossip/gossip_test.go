@@ -1 +1,484 @@
 package gossip_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/core/logging"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+// failingClient records every Message that is sent, but fails to send them.
+type failingClient struct {
+	testutils.MockClient
+}
+
+func (client failingClient) Send(ctx context.Context, to net.Addr, message Message) error {
+	client.MockClient.Send(ctx, to, message)
+	return errors.New("cannot send message")
+}
+
+// rejectingClient sends Messages, but every remote Server rejects them.
+type rejectingClient struct {
+	testutils.MockClient
+}
+
+func (client rejectingClient) Send(ctx context.Context, to net.Addr, message Message) error {
+	client.MockClient.Send(ctx, to, message)
+	return RejectedError{Err: errors.New("cannot accept message")}
+}
+
+// entry is a log entry that was written to a recordingLogger.
+type entry struct {
+	level  logging.Level
+	msg    string
+	fields map[string]interface{}
+}
+
+// recordingLogger records every log entry that is written to it.
+type recordingLogger struct {
+	mu      *sync.Mutex
+	entries *[]entry
+}
+
+func newRecordingLogger() recordingLogger {
+	return recordingLogger{new(sync.Mutex), &[]entry{}}
+}
+
+func (logger recordingLogger) Debug(msg string, fields ...logging.Field) {
+	logger.write(logging.LevelDebug, msg, fields)
+}
+
+func (logger recordingLogger) Info(msg string, fields ...logging.Field) {
+	logger.write(logging.LevelInfo, msg, fields)
+}
+
+func (logger recordingLogger) Warn(msg string, fields ...logging.Field) {
+	logger.write(logging.LevelWarn, msg, fields)
+}
+
+func (logger recordingLogger) Error(msg string, fields ...logging.Field) {
+	logger.write(logging.LevelError, msg, fields)
+}
+
+func (logger recordingLogger) write(level logging.Level, msg string, fields []logging.Field) {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	values := map[string]interface{}{}
+	for _, field := range fields {
+		values[field.Key] = field.Value
+	}
+	*logger.entries = append(*logger.entries, entry{level, msg, values})
+}
+
+func (logger recordingLogger) Entries() []entry {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+
+	return append([]entry{}, *logger.entries...)
+}
+
+var _ = Describe("Gossiper", func() {
+
+	init := func(delegations Delegations) (Gossiper, testutils.MockClient, Messages, net.Addr) {
+		book, err := addr.NewBook(testutils.NewMockAddrs())
+		Expect(err).ShouldNot(HaveOccurred())
+		peer := testutils.RandomAddr()
+		Expect(book.InsertAddr(peer)).ShouldNot(HaveOccurred())
+
+		client := testutils.NewMockClient()
+		messages := testutils.NewMockMessages()
+		gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, delegations, nil, nil, client, messages)
+
+		return gossiper, client, messages, peer
+	}
+
+	Context("when broadcasting a message", func() {
+
+		It("should sign the payload of the message", func() {
+			gossiper, client, _, peer := init(nil)
+			message := NewMessage(1, []byte("key"), []byte("value"), nil)
+			Expect(gossiper.Broadcast(context.Background(), message)).ShouldNot(HaveOccurred())
+
+			Eventually(func() int { return len(client.Sent(peer)) }, time.Second).Should(Equal(1))
+			Expect(client.Sent(peer)[0].Scheme).Should(Equal(testutils.MockScheme))
+			Expect(client.Sent(peer)[0].Signature).Should(Equal(message.Payload()))
+		})
+	})
+
+	Context("when a peer keeps failing", func() {
+
+		It("should evict the peer from the address book", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			peer := testutils.RandomAddr()
+			Expect(book.InsertAddr(peer)).ShouldNot(HaveOccurred())
+			client := failingClient{testutils.NewMockClient()}
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, testutils.NewMockMessages())
+
+			for i := 0; i < addr.MaxFailures; i++ {
+				Expect(gossiper.Broadcast(context.Background(), NewMessage(uint64(i+1), []byte("key"), []byte("value"), nil))).ShouldNot(HaveOccurred())
+				Eventually(func() int { return len(client.Sent(peer)) }, time.Second).Should(Equal(i + 1))
+			}
+			Eventually(func() []net.Addr {
+				addrs, err := book.Addrs(1)
+				Expect(err).ShouldNot(HaveOccurred())
+				return addrs
+			}, time.Second).Should(BeEmpty())
+		})
+	})
+
+	Context("when a peer rejects messages", func() {
+
+		It("should record that the peer responded instead of evicting it", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			peer := testutils.RandomAddr()
+			Expect(book.InsertAddr(peer)).ShouldNot(HaveOccurred())
+			client := rejectingClient{testutils.NewMockClient()}
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, testutils.NewMockMessages())
+
+			for i := 0; i < 2*addr.MaxFailures; i++ {
+				_, err := gossiper.BroadcastSync(context.Background(), NewMessage(uint64(i+1), []byte("key"), []byte("value"), nil))
+				Expect(err).ShouldNot(HaveOccurred())
+			}
+			record, ok := book.Record(peer)
+			Expect(ok).Should(BeTrue())
+			Expect(record.Failures).Should(BeZero())
+			Expect(record.LastSeen.After(record.FirstSeen)).Should(BeTrue())
+		})
+	})
+
+	Context("when logging failures", func() {
+
+		It("should log failed sends with the peer, key, nonce and error", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			peer := testutils.RandomAddr()
+			Expect(book.InsertAddr(peer)).ShouldNot(HaveOccurred())
+			logger := newRecordingLogger()
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, failingClient{testutils.NewMockClient()}, testutils.NewMockMessages(), WithLogger(logger))
+
+			Expect(gossiper.Broadcast(context.Background(), NewMessage(2, []byte("key"), []byte("value"), nil))).ShouldNot(HaveOccurred())
+			Eventually(logger.Entries, time.Second).Should(HaveLen(1))
+			entry := logger.Entries()[0]
+			Expect(entry.level).Should(Equal(logging.LevelDebug))
+			Expect(entry.fields).Should(HaveKeyWithValue("peer", peer.String()))
+			Expect(entry.fields).Should(HaveKeyWithValue("key", "6b6579"))
+			Expect(entry.fields).Should(HaveKeyWithValue("nonce", uint64(2)))
+			Expect(entry.fields).Should(HaveKey("error"))
+		})
+
+		It("should log messages that are rejected", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			logger := newRecordingLogger()
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, testutils.NewMockClient(), testutils.NewMockMessages(), WithLogger(logger))
+			sender := testutils.RandomAddr()
+
+			message := NewMessage(1, []byte("key"), []byte("value"), []byte("invalid"))
+			Expect(gossiper.Receive(WithSender(context.Background(), sender), message)).Should(HaveOccurred())
+			Expect(logger.Entries()).Should(HaveLen(1))
+			entry := logger.Entries()[0]
+			Expect(entry.level).Should(Equal(logging.LevelWarn))
+			Expect(entry.fields).Should(HaveKeyWithValue("peer", sender.String()))
+			Expect(entry.fields).Should(HaveKeyWithValue("nonce", uint64(1)))
+		})
+	})
+
+	Context("when receiving a message", func() {
+
+		It("should store a message with a valid signature", func() {
+			gossiper, _, messages, _ := init(nil)
+			message := NewMessage(1, []byte("key"), []byte("value"), nil)
+			message.Scheme = testutils.MockScheme
+			message.Signature = message.Payload()
+			Expect(gossiper.Receive(context.Background(), message)).ShouldNot(HaveOccurred())
+
+			// The mock signature does not identify a signatory
+			message.Owner = Signatory{}
+			stored, err := messages.Message(message.Key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored).Should(Equal(message))
+		})
+
+		It("should reject a message with an unsupported signature scheme", func() {
+			gossiper, _, messages, _ := init(nil)
+			message := signedMessage("owner", 1, "key", "value")
+			message.Scheme = 0
+			Expect(gossiper.Receive(context.Background(), message)).Should(HaveOccurred())
+
+			stored, err := messages.Message(message.Key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.Nonce).Should(BeZero())
+		})
+
+		It("should reject a signature that is replayed with a different nonce or key", func() {
+			gossiper, _, messages, _ := init(nil)
+			message := NewMessage(1, []byte("key"), []byte("value"), nil)
+			message.Scheme = testutils.MockScheme
+			message.Signature = message.Payload()
+
+			replayedNonce := message
+			replayedNonce.Nonce++
+			Expect(gossiper.Receive(context.Background(), replayedNonce)).Should(HaveOccurred())
+
+			replayedKey := message
+			replayedKey.Key = []byte("another key")
+			Expect(gossiper.Receive(context.Background(), replayedKey)).Should(HaveOccurred())
+
+			stored, err := messages.Message(message.Key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.Nonce).Should(BeZero())
+			stored, err = messages.Message(replayedKey.Key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.Nonce).Should(BeZero())
+		})
+
+		It("should reject an update that is signed by a signatory that does not own the key", func() {
+			gossiper, _, messages, _ := init(nil)
+			message := signedMessage("owner", 1, "key", "value")
+			Expect(gossiper.Receive(context.Background(), message)).ShouldNot(HaveOccurred())
+
+			update := signedMessage("attacker", 2, "key", "another value")
+			Expect(gossiper.Receive(context.Background(), update)).Should(Equal(ErrNotOwner))
+
+			stored, err := messages.Message(message.Key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored).Should(Equal(message))
+		})
+
+		It("should accept an update that is signed by the owner of the key", func() {
+			gossiper, _, messages, _ := init(nil)
+			message := signedMessage("owner", 1, "key", "value")
+			Expect(gossiper.Receive(context.Background(), message)).ShouldNot(HaveOccurred())
+
+			update := signedMessage("owner", 2, "key", "another value")
+			Expect(gossiper.Receive(context.Background(), update)).ShouldNot(HaveOccurred())
+
+			stored, err := messages.Message(message.Key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored).Should(Equal(update))
+		})
+
+		It("should accept updates that are signed by delegates of the owner of the key", func() {
+			delegations := testutils.MockDelegations{
+				"delegate":         Signatory("owner"),
+				"another delegate": Signatory("owner"),
+			}
+			gossiper, _, messages, _ := init(delegations)
+			message := signedMessage("owner", 1, "key", "value")
+			Expect(gossiper.Receive(context.Background(), message)).ShouldNot(HaveOccurred())
+
+			for i, signatory := range []string{"delegate", "another delegate", "owner"} {
+				update := signedMessage(signatory, uint64(i+2), "key", "another value")
+				Expect(gossiper.Receive(context.Background(), update)).ShouldNot(HaveOccurred())
+
+				update.Owner = Signatory("owner")
+				stored, err := messages.Message(message.Key)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(stored).Should(Equal(update))
+			}
+
+			update := signedMessage("attacker", 5, "key", "another value")
+			Expect(gossiper.Receive(context.Background(), update)).Should(Equal(ErrNotOwner))
+		})
+
+		It("should keep the owner of a key when the signature of its message can no longer be verified", func() {
+			gossiper, _, messages, _ := init(nil)
+			message := signedMessage("owner", 1, "key", "value")
+			message.Scheme = 0
+			Expect(messages.InsertMessage(message)).ShouldNot(HaveOccurred())
+
+			update := signedMessage("attacker", 2, "key", "another value")
+			Expect(gossiper.Receive(context.Background(), update)).Should(Equal(ErrNotOwner))
+
+			update = signedMessage("owner", 2, "key", "another value")
+			Expect(gossiper.Receive(context.Background(), update)).ShouldNot(HaveOccurred())
+			stored, err := messages.Message(message.Key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored).Should(Equal(update))
+		})
+
+		It("should resolve the owner of a message that was stored without one", func() {
+			gossiper, _, messages, _ := init(nil)
+			message := signedMessage("owner", 1, "key", "value")
+			message.Owner = nil
+			Expect(messages.InsertMessage(message)).ShouldNot(HaveOccurred())
+
+			update := signedMessage("attacker", 2, "key", "another value")
+			Expect(gossiper.Receive(context.Background(), update)).Should(Equal(ErrNotOwner))
+
+			update = signedMessage("owner", 2, "key", "another value")
+			Expect(gossiper.Receive(context.Background(), update)).ShouldNot(HaveOccurred())
+		})
+
+		It("should ignore the owner that is claimed by the sender", func() {
+			gossiper, _, messages, _ := init(nil)
+			message := signedMessage("owner", 1, "key", "value")
+			Expect(gossiper.Receive(context.Background(), message)).ShouldNot(HaveOccurred())
+
+			update := signedMessage("attacker", 2, "key", "another value")
+			update.Owner = Signatory("owner")
+			Expect(gossiper.Receive(context.Background(), update)).Should(Equal(ErrNotOwner))
+
+			stored, err := messages.Message(message.Key)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored).Should(Equal(message))
+		})
+
+		It("should keep the highest nonce when receiving updates concurrently", func() {
+			gossiper, _, messages, _ := init(nil)
+
+			nonces := rand.Perm(50)
+			var wg sync.WaitGroup
+			for _, nonce := range nonces {
+				wg.Add(1)
+				go func(nonce uint64) {
+					defer GinkgoRecover()
+					defer wg.Done()
+
+					Expect(gossiper.Receive(context.Background(), signedMessage("owner", nonce, "key", "value"))).ShouldNot(HaveOccurred())
+				}(uint64(nonce + 1))
+			}
+			wg.Wait()
+
+			stored, err := messages.Message([]byte("key"))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored.Nonce).Should(Equal(uint64(len(nonces))))
+		})
+	})
+
+	Context("when synchronising with a peer", func() {
+
+		It("should return the messages that are newer or missing, and want the messages that are older", func() {
+			gossiper, _, messages, _ := init(nil)
+			newer := signedMessage("owner", 2, "newer", "value")
+			older := signedMessage("owner", 1, "older", "value")
+			equal := signedMessage("owner", 1, "equal", "value")
+			missing := signedMessage("owner", 1, "missing", "value")
+			for _, message := range []Message{newer, older, equal, missing} {
+				Expect(messages.InsertMessage(message)).ShouldNot(HaveOccurred())
+			}
+
+			digests := []Digest{
+				{Key: newer.Key, Nonce: 1},
+				{Key: older.Key, Nonce: 2},
+				{Key: equal.Key, Nonce: 1},
+				{Key: []byte("unknown"), Nonce: 1},
+			}
+			pulled, wanted, err := gossiper.Sync(context.Background(), nil, digests)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(pulled).Should(ConsistOf(newer, missing))
+			Expect(wanted).Should(ConsistOf(digests[1], digests[3]))
+		})
+
+		It("should pull and push the messages that differ", func() {
+			books := make([]addr.Book, 2)
+			peers := make([]net.Addr, 2)
+			stores := make([]Messages, 2)
+			gossipers := make([]Gossiper, 2)
+			client := testutils.NewMockClient()
+			for i := range gossipers {
+				book, err := addr.NewBook(testutils.NewMockAddrs())
+				Expect(err).ShouldNot(HaveOccurred())
+				books[i] = book
+				peers[i] = testutils.RandomAddr()
+				stores[i] = testutils.NewMockMessages()
+				gossipers[i] = NewGossiper(books[i], 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, stores[i])
+				client.Connect(peers[i], gossipers[i])
+			}
+			Expect(books[0].InsertAddr(peers[1])).ShouldNot(HaveOccurred())
+
+			onlyFirst := signedMessage("owner", 1, "first", "value")
+			onlySecond := signedMessage("owner", 1, "second", "value")
+			older := signedMessage("owner", 1, "shared", "value")
+			newer := signedMessage("owner", 2, "shared", "another value")
+			Expect(stores[0].InsertMessage(onlyFirst)).ShouldNot(HaveOccurred())
+			Expect(stores[0].InsertMessage(older)).ShouldNot(HaveOccurred())
+			Expect(stores[1].InsertMessage(onlySecond)).ShouldNot(HaveOccurred())
+			Expect(stores[1].InsertMessage(newer)).ShouldNot(HaveOccurred())
+
+			Expect(gossipers[0].Synchronise(context.Background())).ShouldNot(HaveOccurred())
+			for _, store := range stores {
+				messages, err := store.Messages()
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(messages).Should(ConsistOf(onlyFirst, onlySecond, newer))
+			}
+		})
+
+		It("should converge on the resolved message when messages conflict with the same nonce", func() {
+			for _, useTree := range []bool{false, true} {
+				books := make([]addr.Book, 2)
+				peers := make([]net.Addr, 2)
+				stores := make([]Messages, 2)
+				gossipers := make([]Gossiper, 2)
+				client := testutils.NewMockClient()
+				for i := range gossipers {
+					book, err := addr.NewBook(testutils.NewMockAddrs())
+					Expect(err).ShouldNot(HaveOccurred())
+					books[i] = book
+					peers[i] = testutils.RandomAddr()
+					stores[i] = testutils.NewMockMessages()
+					if useTree {
+						stores[i], err = NewTree(stores[i])
+						Expect(err).ShouldNot(HaveOccurred())
+					}
+					gossipers[i] = NewGossiper(books[i], 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, stores[i])
+					client.Connect(peers[i], gossipers[i])
+				}
+				Expect(books[0].InsertAddr(peers[1])).ShouldNot(HaveOccurred())
+
+				first := signedMessage("owner", 1, "key", "value")
+				second := signedMessage("owner", 1, "key", "another value")
+				Expect(stores[0].InsertMessage(first)).ShouldNot(HaveOccurred())
+				Expect(stores[1].InsertMessage(second)).ShouldNot(HaveOccurred())
+				winner := first
+				if NewHashResolver().Resolve(first, second) {
+					winner = second
+				}
+
+				Expect(gossipers[0].Synchronise(context.Background())).ShouldNot(HaveOccurred())
+				for _, store := range stores {
+					messages, err := store.Messages()
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(messages).Should(ConsistOf(winner))
+				}
+				if useTree {
+					hashes, err := stores[0].(Tree).Hashes(nil)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(stores[1].(Tree).Hashes(nil)).Should(Equal(hashes))
+				}
+			}
+		})
+
+		It("should do nothing when there are no peers", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, testutils.NewMockClient(), testutils.NewMockMessages())
+			Expect(gossiper.Synchronise(context.Background())).ShouldNot(HaveOccurred())
+		})
+	})
+})
+
+// signedMessage returns a Message that is signed by, and owned by, the
+// `signatory`.
+func signedMessage(signatory string, nonce uint64, key, value string) Message {
+	message := NewMessage(nonce, []byte(key), []byte(value), nil)
+	message.Owner = Signatory(signatory)
+	message.Scheme = testutils.MockScheme
+	signature, err := testutils.MockIdentifiedSigner{Signatory: Signatory(signatory)}.Sign(message.Payload())
+	Expect(err).ShouldNot(HaveOccurred())
+	message.Signature = signature
+	return message
+}
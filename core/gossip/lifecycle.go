@@ -0,0 +1,133 @@
+package gossip
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/republicprotocol/babble-go/core/logging"
+)
+
+// ErrStopped is returned when a Message is broadcast by a Gossiper that has
+// been stopped.
+var ErrStopped = errors.New("gossiper is stopped")
+
+// A lifecycle tracks the sends and background tasks that are bound to the
+// lifetime of a Gossiper.
+type lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      *sync.Mutex
+	started bool
+	stopped bool
+	sends   *sync.WaitGroup
+	tasks   *sync.WaitGroup
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{
+		ctx:    ctx,
+		cancel: cancel,
+
+		mu:    new(sync.Mutex),
+		sends: new(sync.WaitGroup),
+		tasks: new(sync.WaitGroup),
+	}
+}
+
+// begin a send. It returns the context of the lifetime, and a function that
+// must be called when the send is done. It returns ErrStopped if the lifetime
+// is over.
+func (lifecycle *lifecycle) begin() (context.Context, func(), error) {
+	lifecycle.mu.Lock()
+	defer lifecycle.mu.Unlock()
+
+	if lifecycle.stopped {
+		return nil, nil, ErrStopped
+	}
+	lifecycle.sends.Add(1)
+	return lifecycle.ctx, lifecycle.sends.Done, nil
+}
+
+// start returns false if the lifecycle has already been started, or stopped.
+func (lifecycle *lifecycle) start() bool {
+	lifecycle.mu.Lock()
+	defer lifecycle.mu.Unlock()
+
+	if lifecycle.started || lifecycle.stopped {
+		return false
+	}
+	lifecycle.started = true
+	return true
+}
+
+func (lifecycle *lifecycle) isStopped() bool {
+	lifecycle.mu.Lock()
+	defer lifecycle.mu.Unlock()
+
+	return lifecycle.stopped
+}
+
+// Start implements the Gossiper interface.
+func (gossiper *gossiper) Start() error {
+	if gossiper.lifecycle.isStopped() {
+		return ErrStopped
+	}
+	if !gossiper.lifecycle.start() {
+		return nil
+	}
+	gossiper.Go(gossiper.outbound.Run)
+	return nil
+}
+
+// Go implements the Gossiper interface. A task that is started after the
+// Gossiper has been stopped is not run.
+func (gossiper *gossiper) Go(task func(ctx context.Context)) {
+	lifecycle := gossiper.lifecycle
+	lifecycle.mu.Lock()
+	defer lifecycle.mu.Unlock()
+
+	if lifecycle.stopped {
+		return
+	}
+	lifecycle.tasks.Add(1)
+	go func() {
+		defer lifecycle.tasks.Done()
+		task(lifecycle.ctx)
+	}()
+}
+
+// Stop implements the Gossiper interface.
+func (gossiper *gossiper) Stop(ctx context.Context) error {
+	lifecycle := gossiper.lifecycle
+	lifecycle.mu.Lock()
+	lifecycle.stopped = true
+	lifecycle.mu.Unlock()
+
+	// Wait for pending sends, which can no longer be added to now that the
+	// lifecycle is stopped, and then for the Outbound to send the Messages
+	// that they enqueued
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		lifecycle.sends.Wait()
+	}()
+	var err error
+	select {
+	case <-drained:
+		err = gossiper.outbound.Drain(ctx)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if err != nil {
+		gossiper.logger.Warn("cannot drain pending sends", logging.Err(err))
+	}
+
+	lifecycle.cancel()
+	<-drained
+	lifecycle.tasks.Wait()
+	return err
+}
@@ -0,0 +1,164 @@
+package gossip_test
+
+import (
+	"context"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+// blockingClient blocks every send until its context is done.
+type blockingClient struct {
+	testutils.MockClient
+
+	cancelled chan net.Addr
+}
+
+func (client blockingClient) Send(ctx context.Context, to net.Addr, message Message) error {
+	<-ctx.Done()
+	client.cancelled <- to
+	return ctx.Err()
+}
+
+var _ = Describe("Gossiper lifecycle", func() {
+
+	init := func(client Client, outbound bool, n int) (Gossiper, []net.Addr) {
+		book, err := addr.NewBook(testutils.NewMockAddrs())
+		Expect(err).ShouldNot(HaveOccurred())
+		peers := make([]net.Addr, n)
+		for i := range peers {
+			peers[i] = testutils.RandomAddr()
+			Expect(book.InsertAddr(peers[i])).ShouldNot(HaveOccurred())
+		}
+		var out Outbound
+		if outbound {
+			out = NewOutbound(book, client, 100, 2, 0, DefaultTimeout, Block, nil)
+		}
+		gossiper := NewGossiper(book, n, testutils.MockSinger{}, testutils.MockVerifier{}, nil, nil, nil, client, testutils.NewMockMessages(), WithOutbound(out))
+		Expect(gossiper.Start()).ShouldNot(HaveOccurred())
+		return gossiper, peers
+	}
+
+	message := NewMessage(1, []byte("key"), []byte("value"), nil)
+
+	for _, outbound := range []bool{false, true} {
+		outbound := outbound
+
+		Context("when stopping", func() {
+
+			It("should wait for pending sends to drain", func() {
+				client := newSlowClient(20 * time.Millisecond)
+				gossiper, peers := init(client, outbound, 8)
+				Expect(gossiper.Broadcast(context.Background(), message)).ShouldNot(HaveOccurred())
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				Expect(gossiper.Stop(ctx)).ShouldNot(HaveOccurred())
+				for _, peer := range peers {
+					Expect(client.Sent(peer)).Should(HaveLen(1))
+				}
+			})
+
+			It("should cancel sends that are still in flight after the deadline", func() {
+				client := blockingClient{testutils.NewMockClient(), make(chan net.Addr, 2)}
+				gossiper, _ := init(client, outbound, 2)
+				Expect(gossiper.Broadcast(context.Background(), message)).ShouldNot(HaveOccurred())
+
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				defer cancel()
+				Expect(gossiper.Stop(ctx)).Should(Equal(context.DeadlineExceeded))
+				Eventually(client.cancelled).Should(Receive())
+			})
+
+			It("should not count cancelled sends as failures", func() {
+				book, err := addr.NewBook(testutils.NewMockAddrs())
+				Expect(err).ShouldNot(HaveOccurred())
+				peer := testutils.RandomAddr()
+				Expect(book.InsertAddr(peer)).ShouldNot(HaveOccurred())
+				client := blockingClient{testutils.NewMockClient(), make(chan net.Addr, 2)}
+				var out Outbound
+				if outbound {
+					out = NewOutbound(book, client, 100, 2, 0, DefaultTimeout, Block, nil)
+				}
+				gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockVerifier{}, nil, nil, nil, client, testutils.NewMockMessages(), WithOutbound(out))
+
+				// A synchronous broadcast whose context is done
+				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+				defer cancel()
+				report, err := gossiper.BroadcastSync(ctx, message)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(report.Acknowledged()).Should(BeEmpty())
+				Eventually(client.cancelled).Should(Receive())
+
+				// A broadcast that is cancelled by Stop
+				Expect(gossiper.Broadcast(context.Background(), message)).ShouldNot(HaveOccurred())
+				ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+				defer cancel()
+				Expect(gossiper.Stop(ctx)).Should(Equal(context.DeadlineExceeded))
+				Eventually(client.cancelled).Should(Receive())
+
+				record, ok := book.Record(peer)
+				Expect(ok).Should(BeTrue())
+				Expect(record.Failures).Should(Equal(0))
+			})
+
+			It("should send every broadcast that was accepted while stopping", func() {
+				client := testutils.NewMockClient()
+				gossiper, peers := init(client, outbound, 1)
+
+				accepted := make(chan int, 4)
+				for i := 0; i < cap(accepted); i++ {
+					go func() {
+						defer GinkgoRecover()
+						n := 0
+						for gossiper.Broadcast(context.Background(), message) == nil {
+							n++
+						}
+						accepted <- n
+					}()
+				}
+				time.Sleep(10 * time.Millisecond)
+				Expect(gossiper.Stop(context.Background())).ShouldNot(HaveOccurred())
+
+				total := 0
+				for i := 0; i < cap(accepted); i++ {
+					total += <-accepted
+				}
+				Expect(client.Sent(peers[0])).Should(HaveLen(total))
+			})
+
+			It("should reject new broadcasts", func() {
+				gossiper, _ := init(testutils.NewMockClient(), outbound, 1)
+				Expect(gossiper.Stop(context.Background())).ShouldNot(HaveOccurred())
+				Expect(gossiper.Broadcast(context.Background(), message)).Should(Equal(ErrStopped))
+				Expect(gossiper.Start()).Should(Equal(ErrStopped))
+			})
+		})
+	}
+
+	Context("when running background tasks", func() {
+
+		It("should stop them when the gossiper is stopped", func() {
+			gossiper, _ := init(testutils.NewMockClient(), false, 1)
+			stopped := make(chan struct{})
+			gossiper.Go(func(ctx context.Context) {
+				<-ctx.Done()
+				close(stopped)
+			})
+
+			Expect(gossiper.Stop(context.Background())).ShouldNot(HaveOccurred())
+			Expect(stopped).Should(BeClosed())
+
+			// Tasks are not run after the gossiper is stopped
+			ran := false
+			gossiper.Go(func(ctx context.Context) { ran = true })
+			Consistently(func() bool { return ran }, 10*time.Millisecond).Should(BeFalse())
+		})
+	})
+})
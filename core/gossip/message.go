@@ -1,20 +1,60 @@
 package gossip
 
+import "encoding/binary"
+
+// MessageVersion is the version of the encoding returned by `Message.Payload`.
+// It is written as the first byte of every payload so that fields can be added
+// to a Message without a payload of one version being mistaken for a payload
+// of another.
+const MessageVersion = byte(1)
+
 // A Message is a unit of data that can be disseminated throughout the network.
 // An outdated Message can be overwritten by disseminating a newer Message with
 // the same `Key` but an incremented `Nonce`. Nodes in the network will discard
 // the lower `Nonce` Message in favour of the higher `Nonce` Message. A
-// `Signature` is used to verify the authenticity of the Message.
+// `Signature` is used to verify the authenticity of the Message, and the
+// `Scheme` identifies the signature scheme that produced it.
+//
+// The `Owner` is resolved by a Gossiper when it receives the Message, and is
+// stored with it, so that the ownership of the `Key` can be checked without
+// verifying the `Signature` of the stored Message again. It is not part of the
+// Payload, and the `Owner` of a Message that is received from a remote peer
+// is ignored.
 type Message struct {
-	Nonce     uint64 `json:"nonce"`
-	Key       []byte `json:"key"`
-	Value     []byte `json:"value"`
-	Signature []byte `json:"signature"`
+	Nonce     uint64    `json:"nonce"`
+	Key       []byte    `json:"key"`
+	Value     []byte    `json:"value"`
+	Scheme    Scheme    `json:"scheme"`
+	Signature []byte    `json:"signature"`
+	Owner     Signatory `json:"owner,omitempty"`
 }
 
 // NewMessage returns a new Message with given nonce, key, value and signature.
+// The Message has no signature Scheme until it is signed by a Gossiper.
 func NewMessage(nonce uint64, key, value, signature []byte) Message {
-	return Message{nonce, key, value, signature}
+	return Message{
+		Nonce:     nonce,
+		Key:       key,
+		Value:     value,
+		Signature: signature,
+	}
+}
+
+// Payload returns the canonical encoding of the Message. It is the data that
+// is signed by a Signer and verified by a Verifier, and it covers every field
+// of the Message except the `Scheme`, the `Signature` and the `Owner`. This prevents a
+// signature from being replayed with a different `Nonce` or `Key`.
+//
+// The payload is the `MessageVersion`, followed by the big-endian `Nonce`,
+// followed by the `Key` and the `Value`, each prefixed with its big-endian
+// uint32 length.
+func (message Message) Payload() []byte {
+	payload := make([]byte, 0, 1+8+4+len(message.Key)+4+len(message.Value))
+	payload = append(payload, MessageVersion)
+	payload = appendUint64(payload, message.Nonce)
+	payload = appendBytes(payload, message.Key)
+	payload = appendBytes(payload, message.Value)
+	return payload
 }
 
 // Messages is used to read and write Messages to persistent storage.
@@ -25,8 +65,44 @@ type Messages interface {
 	// be overwritten.
 	InsertMessage(message Message) error
 
+	// InsertMessageIfNewer atomically inserts the Message into the store, but
+	// only if it is newer than the existing Message with the same key, as
+	// decided by IsNewer using the `resolver`. It returns true if the Message
+	// was inserted, and false if it was not.
+	InsertMessageIfNewer(message Message, resolver Resolver) (bool, error)
+
 	// Message returns a previously inserted Message associated with the key.
 	// It returns an empty message with zero nonce if there is no message with
 	// the associated key in the store.
 	Message(key []byte) (Message, error)
+
+	// Messages returns all Messages in the store.
+	Messages() ([]Message, error)
+
+	// MessagesWithPrefix returns Messages with a key that begins with the
+	// prefix, in ascending order of key. It returns at most `limit` Messages,
+	// unless the limit is zero, in which case there is no limit. The Messages
+	// begin at the first key that is greater than, or equal to, the cursor.
+	// If there are more Messages, it returns the cursor of the next page,
+	// otherwise it returns a nil cursor. A nil prefix matches all Messages,
+	// and a nil cursor begins at the first Message.
+	MessagesWithPrefix(prefix, cursor []byte, limit int) ([]Message, []byte, error)
+}
+
+// NextCursor returns the cursor that begins immediately after a key. It can be
+// used by implementations of Messages to paginate Messages.
+func NextCursor(key []byte) []byte {
+	return append(append([]byte{}, key...), 0x00)
+}
+
+func appendUint64(data []byte, n uint64) []byte {
+	buf := [8]byte{}
+	binary.BigEndian.PutUint64(buf[:], n)
+	return append(data, buf[:]...)
+}
+
+func appendBytes(data []byte, value []byte) []byte {
+	buf := [4]byte{}
+	binary.BigEndian.PutUint32(buf[:], uint32(len(value)))
+	return append(append(data, buf[:]...), value...)
 }
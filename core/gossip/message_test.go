@@ -1 +1,44 @@
 package gossip_test
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+)
+
+var _ = Describe("Message", func() {
+
+	Context("when encoding the payload", func() {
+
+		It("should begin with the message version", func() {
+			message := NewMessage(1, []byte("key"), []byte("value"), nil)
+			Expect(message.Payload()[0]).Should(Equal(MessageVersion))
+		})
+
+		It("should not depend on the signature", func() {
+			message := NewMessage(1, []byte("key"), []byte("value"), []byte("signature"))
+			other := NewMessage(1, []byte("key"), []byte("value"), []byte("another signature"))
+			Expect(message.Payload()).Should(Equal(other.Payload()))
+		})
+
+		It("should be different when any field other than the signature is different", func() {
+			message := NewMessage(1, []byte("key"), []byte("value"), nil)
+			others := []Message{
+				NewMessage(2, []byte("key"), []byte("value"), nil),
+				NewMessage(1, []byte("another key"), []byte("value"), nil),
+				NewMessage(1, []byte("key"), []byte("another value"), nil),
+			}
+			for _, other := range others {
+				Expect(bytes.Equal(message.Payload(), other.Payload())).Should(BeFalse())
+			}
+		})
+
+		It("should not be ambiguous about where the key ends and the value begins", func() {
+			message := NewMessage(1, []byte("ke"), []byte("yvalue"), nil)
+			other := NewMessage(1, []byte("key"), []byte("value"), nil)
+			Expect(bytes.Equal(message.Payload(), other.Payload())).Should(BeFalse())
+		})
+	})
+})
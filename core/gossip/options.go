@@ -0,0 +1,68 @@
+package gossip
+
+import (
+	"net"
+
+	"github.com/republicprotocol/babble-go/core/logging"
+)
+
+// An Option configures an optional dependency of a Gossiper.
+type Option func(*options)
+
+type options struct {
+	selector PeerSelector
+	outbound Outbound
+	logger   logging.Logger
+	self     net.Addr
+}
+
+// WithSelector sets the PeerSelector that selects the peers that Messages are
+// sent to. By default, peers are selected by the PeerSelector returned by
+// NewRandomSelector.
+func WithSelector(selector PeerSelector) Option {
+	return func(options *options) {
+		options.selector = selector
+	}
+}
+
+// WithOutbound sets the Outbound that broadcasts send their Messages through.
+// The Outbound is run by the Gossiper once it has been started. By default,
+// the Outbound returned by NewOutbound is used, with DefaultQueueSize,
+// DefaultWorkers, DefaultPerPeer and the Block OverflowPolicy.
+func WithOutbound(outbound Outbound) Option {
+	return func(options *options) {
+		options.outbound = outbound
+	}
+}
+
+// WithLogger sets the `logging.Logger` that failures are logged by. By default,
+// failures are logged by the `logging.Logger` returned by
+// `logging.NewDefaultLogger`.
+func WithLogger(logger logging.Logger) Option {
+	return func(options *options) {
+		options.logger = logger
+	}
+}
+
+// WithSelf sets the `net.Addr` at which the Gossiper can be reached, so that it
+// is never learned from other peers. By default, the Gossiper does not know its
+// own `net.Addr`.
+func WithSelf(self net.Addr) Option {
+	return func(options *options) {
+		options.self = self
+	}
+}
+
+func newOptions(opts []Option) options {
+	options := options{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.selector == nil {
+		options.selector = NewRandomSelector()
+	}
+	if options.logger == nil {
+		options.logger = logging.NewDefaultLogger()
+	}
+	return options
+}
@@ -0,0 +1,363 @@
+package gossip
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/core/logging"
+)
+
+// ErrQueueFull is returned when a Message cannot be enqueued because the queue
+// of an Outbound is full, and its OverflowPolicy is Reject.
+var ErrQueueFull = errors.New("outbound queue is full")
+
+const (
+	// DefaultQueueSize is the number of Messages that can be queued by the
+	// Outbound of a Gossiper that was not given one.
+	DefaultQueueSize = 1024
+
+	// DefaultWorkers is the number of workers of the Outbound of a Gossiper
+	// that was not given one.
+	DefaultWorkers = 32
+
+	// DefaultPerPeer is the number of Messages that the Outbound of a
+	// Gossiper that was not given one sends to the same `net.Addr` at once.
+	DefaultPerPeer = 4
+
+	// DefaultTimeout is the time that the Outbound of a Gossiper that was not
+	// given one waits for an enqueued Message to be sent.
+	DefaultTimeout = time.Minute
+)
+
+// An OverflowPolicy decides what an Outbound does when a Message is enqueued
+// while its queue is full.
+type OverflowPolicy int
+
+const (
+	// Block until there is space in the queue, or until the context is done.
+	// Broadcasting and receiving Messages slows down to the rate at which
+	// they can be sent.
+	Block OverflowPolicy = iota
+
+	// DropOldest drops the oldest Message in the queue to make space.
+	DropOldest
+
+	// Reject the Message and return ErrQueueFull.
+	Reject
+)
+
+// An Outbound schedules Messages to be sent to remote peers, so that a burst of
+// broadcasts is sent by a fixed number of workers instead of an unbounded
+// number of goroutines.
+type Outbound interface {
+
+	// Enqueue a Message to be sent to a `net.Addr`. What happens when the
+	// queue is full depends on the OverflowPolicy of the Outbound.
+	Enqueue(ctx context.Context, to net.Addr, message Message) error
+
+	// Send a Message to a `net.Addr` through the queue, like Enqueue, but
+	// wait until it has been sent, or until the context is done. It returns
+	// the error of the send, and ErrQueueFull if the Message was dropped from
+	// the queue before it was sent. The Message is only sent while the
+	// context is not done, and the context is passed to the Client, so its
+	// deadline is used instead of the timeout of the Outbound.
+	Send(ctx context.Context, to net.Addr, message Message) error
+
+	// Run the workers of the Outbound until the context is done. Messages are
+	// only sent while the Outbound is running, and Messages that are being
+	// sent when the context is done are cancelled.
+	Run(ctx context.Context)
+
+	// Drain blocks until every queued Message has been sent, or until the
+	// context is done, in which case the error of the context is returned.
+	Drain(ctx context.Context) error
+}
+
+type job struct {
+	to      net.Addr
+	message Message
+
+	// ctx and done are only set for jobs that are waited on by Send
+	ctx  context.Context
+	done chan error
+}
+
+// finish reports the result of the job to Send, if it is waiting for one.
+func (job job) finish(err error) {
+	if job.done != nil {
+		job.done <- err
+	}
+}
+
+// isCancelled returns true if the job is waited on by a Send whose context is
+// done.
+func (job job) isCancelled() bool {
+	return job.ctx != nil && job.ctx.Err() != nil
+}
+
+type outbound struct {
+	addrBook addr.Book
+	client   Client
+	workers  int
+	perPeer  int
+	timeout  time.Duration
+	policy   OverflowPolicy
+	logger   logging.Logger
+
+	// slots has one token for every queued job, so that enqueueing can block
+	// on a full queue without holding the lock
+	slots chan struct{}
+
+	mu     *sync.Mutex
+	cond   *sync.Cond
+	queue  []job
+	active map[string]int
+}
+
+// NewOutbound returns an Outbound that sends Messages using the `client`, and
+// records whether or not each `net.Addr` responded in the `addrBook`. At most
+// `queueSize` Messages are queued, and they are sent by `workers` workers. At
+// most `perPeer` Messages are sent to the same `net.Addr` at once, unless it
+// is zero, in which case there is no limit per `net.Addr`. Enqueued Messages
+// are cancelled if they are not sent within the `timeout`. The `logger` can be
+// nil, in which case the default `logging.Logger` is used.
+func NewOutbound(addrBook addr.Book, client Client, queueSize, workers, perPeer int, timeout time.Duration, policy OverflowPolicy, logger logging.Logger) Outbound {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	mu := new(sync.Mutex)
+	return &outbound{
+		addrBook: addrBook,
+		client:   client,
+		workers:  workers,
+		perPeer:  perPeer,
+		timeout:  timeout,
+		policy:   policy,
+		logger:   logger,
+
+		slots: make(chan struct{}, queueSize),
+
+		mu:     mu,
+		cond:   sync.NewCond(mu),
+		queue:  make([]job, 0, queueSize),
+		active: map[string]int{},
+	}
+}
+
+// Enqueue implements the Outbound interface.
+func (outbound *outbound) Enqueue(ctx context.Context, to net.Addr, message Message) error {
+	return outbound.enqueue(ctx, job{to: to, message: message})
+}
+
+// Send implements the Outbound interface.
+func (outbound *outbound) Send(ctx context.Context, to net.Addr, message Message) error {
+	job := job{to: to, message: message, ctx: ctx, done: make(chan error, 1)}
+	if err := outbound.enqueue(ctx, job); err != nil {
+		return err
+	}
+	select {
+	case err := <-job.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (outbound *outbound) enqueue(ctx context.Context, job job) error {
+	for {
+		select {
+		case outbound.slots <- struct{}{}:
+			outbound.push(job)
+			return nil
+		default:
+		}
+
+		switch outbound.policy {
+		case Reject:
+			outbound.logger.Warn("cannot enqueue message", logging.Peer(job.to), logging.Key(job.message.Key), logging.Nonce(job.message.Nonce), logging.Err(ErrQueueFull))
+			return ErrQueueFull
+		case DropOldest:
+			if dropped, ok := outbound.replaceOldest(job); ok {
+				outbound.logger.Warn("dropped oldest message", logging.Peer(dropped.to), logging.Key(dropped.message.Key), logging.Nonce(dropped.message.Nonce), logging.Err(ErrQueueFull))
+				dropped.finish(ErrQueueFull)
+				return nil
+			}
+			// The queue was drained after the slot could not be taken, so
+			// there is space in the queue again
+		default:
+			select {
+			case outbound.slots <- struct{}{}:
+				outbound.push(job)
+				return nil
+			case <-ctx.Done():
+				outbound.logger.Warn("cannot enqueue message", logging.Peer(job.to), logging.Key(job.message.Key), logging.Nonce(job.message.Nonce), logging.Err(ctx.Err()))
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// Run implements the Outbound interface.
+func (outbound *outbound) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		outbound.mu.Lock()
+		defer outbound.mu.Unlock()
+		outbound.cond.Broadcast()
+	}()
+
+	wg := new(sync.WaitGroup)
+	wg.Add(outbound.workers)
+	for i := 0; i < outbound.workers; i++ {
+		go func() {
+			defer wg.Done()
+			outbound.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// Drain implements the Outbound interface.
+func (outbound *outbound) Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			outbound.mu.Lock()
+			defer outbound.mu.Unlock()
+			outbound.cond.Broadcast()
+		case <-done:
+		}
+	}()
+
+	outbound.mu.Lock()
+	defer outbound.mu.Unlock()
+
+	for len(outbound.queue) > 0 || len(outbound.active) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		outbound.cond.Wait()
+	}
+	return nil
+}
+
+func (outbound *outbound) work(ctx context.Context) {
+	for {
+		job, ok := outbound.next(ctx)
+		if !ok {
+			return
+		}
+
+		func() {
+			ctx, cancel := outbound.sendContext(ctx, job)
+			defer cancel()
+
+			err := send(ctx, outbound.addrBook, outbound.client, outbound.logger, job.to, job.message)
+			if err != nil && job.done == nil {
+				outbound.logger.Debug("cannot send message", logging.Peer(job.to), logging.Key(job.message.Key), logging.Nonce(job.message.Nonce), logging.Err(err))
+			}
+			job.finish(err)
+		}()
+
+		outbound.mu.Lock()
+		outbound.active[job.to.String()]--
+		if outbound.active[job.to.String()] <= 0 {
+			delete(outbound.active, job.to.String())
+		}
+		outbound.cond.Broadcast()
+		outbound.mu.Unlock()
+	}
+}
+
+// sendContext returns the context that a job is sent with. An enqueued job is
+// given the timeout of the Outbound. A job that is waited on by Send is given
+// the context of its caller, so that its deadline and values are kept. Either
+// way, the job is cancelled when the Outbound stops running.
+func (outbound *outbound) sendContext(ctx context.Context, job job) (context.Context, context.CancelFunc) {
+	if job.ctx == nil {
+		return context.WithTimeout(ctx, outbound.timeout)
+	}
+
+	sendCtx, cancel := context.WithCancel(job.ctx)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-sendCtx.Done():
+		}
+	}()
+	return sendCtx, cancel
+}
+
+// next blocks until there is a queued job for a `net.Addr` that is below its
+// concurrency limit, and removes it from the queue. Jobs whose Send is no
+// longer waiting are removed without being returned. It returns false when the
+// context is done.
+func (outbound *outbound) next(ctx context.Context) (job, bool) {
+	outbound.mu.Lock()
+	defer outbound.mu.Unlock()
+
+	for {
+		if ctx.Err() != nil {
+			return job{}, false
+		}
+		outbound.removeCancelled()
+		for i, job := range outbound.queue {
+			if outbound.perPeer > 0 && outbound.active[job.to.String()] >= outbound.perPeer {
+				continue
+			}
+			outbound.queue = append(outbound.queue[:i], outbound.queue[i+1:]...)
+			outbound.active[job.to.String()]++
+			<-outbound.slots
+			return job, true
+		}
+		outbound.cond.Wait()
+	}
+}
+
+// removeCancelled removes the jobs whose Send is no longer waiting from the
+// queue. It must be called while holding the lock.
+func (outbound *outbound) removeCancelled() {
+	queue := outbound.queue[:0]
+	for _, job := range outbound.queue {
+		if !job.isCancelled() {
+			queue = append(queue, job)
+			continue
+		}
+		<-outbound.slots
+		job.finish(job.ctx.Err())
+	}
+	if len(queue) < len(outbound.queue) {
+		outbound.queue = queue
+		outbound.cond.Broadcast()
+	}
+}
+
+func (outbound *outbound) push(job job) {
+	outbound.mu.Lock()
+	defer outbound.mu.Unlock()
+
+	outbound.queue = append(outbound.queue, job)
+	outbound.cond.Broadcast()
+}
+
+// replaceOldest drops the oldest queued job and queues the new job in its
+// slot. It returns the dropped job, and false if there are no queued jobs.
+func (outbound *outbound) replaceOldest(newJob job) (job, bool) {
+	outbound.mu.Lock()
+	defer outbound.mu.Unlock()
+
+	if len(outbound.queue) == 0 {
+		return job{}, false
+	}
+	dropped := outbound.queue[0]
+	outbound.queue = append(outbound.queue[1:], newJob)
+	outbound.cond.Broadcast()
+	return dropped, true
+}
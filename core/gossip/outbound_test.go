@@ -0,0 +1,321 @@
+package gossip_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+// slowClient records every Message that is sent, and how many Messages are
+// being sent at once, in total and to each `net.Addr`.
+type slowClient struct {
+	testutils.MockClient
+
+	delay      time.Duration
+	mu         *sync.Mutex
+	active     map[string]int
+	maxActive  *int
+	maxPerPeer *int
+}
+
+func newSlowClient(delay time.Duration) slowClient {
+	return slowClient{
+		MockClient: testutils.NewMockClient(),
+
+		delay:      delay,
+		mu:         new(sync.Mutex),
+		active:     map[string]int{},
+		maxActive:  new(int),
+		maxPerPeer: new(int),
+	}
+}
+
+func (client slowClient) Send(ctx context.Context, to net.Addr, message Message) error {
+	client.mu.Lock()
+	client.active[""]++
+	client.active[to.String()]++
+	if client.active[""] > *client.maxActive {
+		*client.maxActive = client.active[""]
+	}
+	if client.active[to.String()] > *client.maxPerPeer {
+		*client.maxPerPeer = client.active[to.String()]
+	}
+	client.mu.Unlock()
+
+	time.Sleep(client.delay)
+
+	client.mu.Lock()
+	client.active[""]--
+	client.active[to.String()]--
+	client.mu.Unlock()
+	return client.MockClient.Send(ctx, to, message)
+}
+
+func (client slowClient) Max() (int, int) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return *client.maxActive, *client.maxPerPeer
+}
+
+type valueKey struct{}
+
+// waitingClient takes a delay to send a Message, unless its context is done
+// first. It records the error of every send, and the value of every context
+// that it is given.
+type waitingClient struct {
+	testutils.MockClient
+
+	delay  time.Duration
+	mu     *sync.Mutex
+	errs   *[]error
+	values *[]interface{}
+}
+
+func newWaitingClient(delay time.Duration) waitingClient {
+	return waitingClient{testutils.NewMockClient(), delay, new(sync.Mutex), new([]error), new([]interface{})}
+}
+
+func (client waitingClient) Send(ctx context.Context, to net.Addr, message Message) error {
+	var err error
+	select {
+	case <-time.After(client.delay):
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	*client.errs = append(*client.errs, err)
+	*client.values = append(*client.values, ctx.Value(valueKey{}))
+	return err
+}
+
+func (client waitingClient) Results() ([]error, []interface{}) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return append([]error{}, *client.errs...), append([]interface{}{}, *client.values...)
+}
+
+var _ = Describe("Outbound", func() {
+
+	newBook := func() addr.Book {
+		book, err := addr.NewBook(testutils.NewMockAddrs())
+		Expect(err).ShouldNot(HaveOccurred())
+		return book
+	}
+
+	message := func(nonce uint64) Message {
+		return NewMessage(nonce, []byte("key"), []byte("value"), nil)
+	}
+
+	Context("when running", func() {
+
+		It("should not send more messages at once than there are workers", func() {
+			client := newSlowClient(10 * time.Millisecond)
+			outbound := NewOutbound(newBook(), client, 100, 4, 0, DefaultTimeout, Block, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go outbound.Run(ctx)
+
+			peers := make([]net.Addr, 40)
+			for i := range peers {
+				peers[i] = testutils.RandomAddr()
+				Expect(outbound.Enqueue(ctx, peers[i], message(1))).ShouldNot(HaveOccurred())
+			}
+			Eventually(func() int {
+				sent := 0
+				for _, peer := range peers {
+					sent += len(client.Sent(peer))
+				}
+				return sent
+			}, 5*time.Second).Should(Equal(len(peers)))
+			maxActive, _ := client.Max()
+			Expect(maxActive).Should(BeNumerically("<=", 4))
+		})
+
+		It("should not send more messages at once to a peer than its limit", func() {
+			client := newSlowClient(10 * time.Millisecond)
+			outbound := NewOutbound(newBook(), client, 100, 8, 2, DefaultTimeout, Block, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go outbound.Run(ctx)
+
+			peer := testutils.RandomAddr()
+			for nonce := uint64(1); nonce <= 20; nonce++ {
+				Expect(outbound.Enqueue(ctx, peer, message(nonce))).ShouldNot(HaveOccurred())
+			}
+			Eventually(func() int { return len(client.Sent(peer)) }, 5*time.Second).Should(Equal(20))
+			_, maxPerPeer := client.Max()
+			Expect(maxPerPeer).Should(BeNumerically("<=", 2))
+		})
+
+		It("should record whether or not peers responded", func() {
+			book := newBook()
+			peer := testutils.RandomAddr()
+			Expect(book.InsertAddr(peer)).ShouldNot(HaveOccurred())
+			client := testutils.NewMockClient()
+			outbound := NewOutbound(book, failingClient{client}, 10, 1, 0, DefaultTimeout, Block, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go outbound.Run(ctx)
+
+			Expect(outbound.Enqueue(ctx, peer, message(1))).ShouldNot(HaveOccurred())
+			Eventually(func() int {
+				record, _ := book.Record(peer)
+				return record.Failures
+			}, time.Second).Should(Equal(1))
+		})
+	})
+
+	Context("when sending and waiting", func() {
+
+		It("should return the error of the send", func() {
+			book := newBook()
+			peer := testutils.RandomAddr()
+			Expect(book.InsertAddr(peer)).ShouldNot(HaveOccurred())
+			client := testutils.NewMockClient()
+			outbound := NewOutbound(book, failingClient{client}, 10, 1, 0, DefaultTimeout, Block, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go outbound.Run(ctx)
+
+			Expect(outbound.Send(ctx, peer, message(1))).Should(HaveOccurred())
+
+			// An Outbound that is not running never sends the Message
+			done, cancelDone := context.WithCancel(context.Background())
+			cancelDone()
+			Expect(NewOutbound(book, client, 10, 1, 0, DefaultTimeout, Block, nil).Send(done, peer, message(1))).Should(Equal(context.Canceled))
+		})
+
+		It("should not send messages once the context is done", func() {
+			client := newSlowClient(100 * time.Millisecond)
+			outbound := NewOutbound(newBook(), client, 10, 1, 0, DefaultTimeout, Block, nil)
+			peer := testutils.RandomAddr()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			Expect(outbound.Send(ctx, peer, message(1))).Should(Equal(context.DeadlineExceeded))
+
+			runCtx, stop := context.WithCancel(context.Background())
+			defer stop()
+			go outbound.Run(runCtx)
+			Expect(outbound.Drain(context.Background())).ShouldNot(HaveOccurred())
+			Expect(client.Sent(peer)).Should(BeEmpty())
+		})
+
+		It("should use the deadline and values of the context instead of the timeout", func() {
+			client := newWaitingClient(100 * time.Millisecond)
+			outbound := NewOutbound(newBook(), client, 10, 1, 0, 10*time.Millisecond, Block, nil)
+			peer := testutils.RandomAddr()
+			runCtx, stop := context.WithCancel(context.Background())
+			defer stop()
+			go outbound.Run(runCtx)
+
+			ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), valueKey{}, "value"), 5*time.Second)
+			defer cancel()
+			Expect(outbound.Send(ctx, peer, message(1))).ShouldNot(HaveOccurred())
+
+			// An enqueued Message is cancelled after the timeout
+			Expect(outbound.Enqueue(ctx, peer, message(2))).ShouldNot(HaveOccurred())
+			Expect(outbound.Drain(ctx)).ShouldNot(HaveOccurred())
+			errs, values := client.Results()
+			Expect(errs).Should(Equal([]error{nil, context.DeadlineExceeded}))
+			Expect(values).Should(Equal([]interface{}{"value", nil}))
+		})
+
+		It("should bound synchronous broadcasts by default", func() {
+			book := newBook()
+			peer := testutils.RandomAddr()
+			Expect(book.InsertAddr(peer)).ShouldNot(HaveOccurred())
+			client := newSlowClient(10 * time.Millisecond)
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockVerifier{}, nil, nil, nil, client, testutils.NewMockMessages())
+			defer gossiper.Stop(context.Background())
+
+			wg := new(sync.WaitGroup)
+			for nonce := uint64(1); nonce <= 20; nonce++ {
+				wg.Add(1)
+				go func(nonce uint64) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					_, err := gossiper.BroadcastSync(context.Background(), message(nonce))
+					Expect(err).ShouldNot(HaveOccurred())
+				}(nonce)
+			}
+			wg.Wait()
+			Expect(client.Sent(peer)).Should(HaveLen(20))
+			_, maxPerPeer := client.Max()
+			Expect(maxPerPeer).Should(BeNumerically("<=", DefaultPerPeer))
+		})
+	})
+
+	Context("when the queue is full", func() {
+
+		It("should block until the context is done", func() {
+			outbound := NewOutbound(newBook(), testutils.NewMockClient(), 1, 1, 0, DefaultTimeout, Block, nil)
+			peer := testutils.RandomAddr()
+			Expect(outbound.Enqueue(context.Background(), peer, message(1))).ShouldNot(HaveOccurred())
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			Expect(outbound.Enqueue(ctx, peer, message(2))).Should(Equal(context.DeadlineExceeded))
+		})
+
+		It("should unblock when the queue is drained", func() {
+			client := testutils.NewMockClient()
+			outbound := NewOutbound(newBook(), client, 1, 1, 0, DefaultTimeout, Block, nil)
+			peer := testutils.RandomAddr()
+			Expect(outbound.Enqueue(context.Background(), peer, message(1))).ShouldNot(HaveOccurred())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				outbound.Run(ctx)
+			}()
+			Expect(outbound.Enqueue(ctx, peer, message(2))).ShouldNot(HaveOccurred())
+			Eventually(func() int { return len(client.Sent(peer)) }, time.Second).Should(Equal(2))
+		})
+
+		It("should drop the oldest message", func() {
+			client := testutils.NewMockClient()
+			outbound := NewOutbound(newBook(), client, 2, 1, 0, DefaultTimeout, DropOldest, nil)
+			peer := testutils.RandomAddr()
+			for nonce := uint64(1); nonce <= 3; nonce++ {
+				Expect(outbound.Enqueue(context.Background(), peer, message(nonce))).ShouldNot(HaveOccurred())
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go outbound.Run(ctx)
+			Eventually(func() int { return len(client.Sent(peer)) }, time.Second).Should(Equal(2))
+			Expect(client.Sent(peer)).Should(Equal([]Message{message(2), message(3)}))
+		})
+
+		It("should return an error from broadcasts", func() {
+			book := newBook()
+			Expect(book.InsertAddr(testutils.RandomAddr())).ShouldNot(HaveOccurred())
+			client := newSlowClient(time.Second)
+			outbound := NewOutbound(book, client, 1, 1, 0, DefaultTimeout, Reject, nil)
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockVerifier{}, nil, nil, nil, client, testutils.NewMockMessages(), WithOutbound(outbound))
+			defer gossiper.Stop(context.Background())
+
+			// The first Message is being sent, and the second is queued,
+			// unless the first has not been dequeued yet
+			Expect(gossiper.Broadcast(context.Background(), message(1))).ShouldNot(HaveOccurred())
+			nonce := uint64(1)
+			Eventually(func() error {
+				nonce++
+				return gossiper.Broadcast(context.Background(), message(nonce))
+			}).Should(Equal(ErrQueueFull))
+			Expect(nonce).Should(BeNumerically("<=", 3))
+		})
+	})
+})
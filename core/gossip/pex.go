@@ -0,0 +1,107 @@
+package gossip
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/core/logging"
+)
+
+// MaxPeers is the maximum number of `net.Addr` that are exchanged in one round
+// of peer exchange.
+const MaxPeers = 32
+
+// RunPeerExchange calls `ExchangePeers` on the Gossiper once every period until
+// the context is done, so that a node that only knows a few peers eventually
+// learns about the rest of the network. Errors are logged by the `logger`, and
+// do not stop future rounds. The `logger` can be nil, in which case the
+// default `logging.Logger` is used.
+func RunPeerExchange(ctx context.Context, gossiper Gossiper, period time.Duration, logger logging.Logger) {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := gossiper.ExchangePeers(ctx); err != nil {
+			logger.Debug("cannot exchange peers", logging.Err(err))
+		}
+	}
+}
+
+// Peers implements the Server interface.
+func (gossiper *gossiper) Peers(ctx context.Context, n int) ([]net.Addr, error) {
+	if n > MaxPeers {
+		n = MaxPeers
+	}
+	if n < 0 {
+		n = 0
+	}
+	return gossiper.addrBook.Addrs(n)
+}
+
+// ExchangePeers implements the Gossiper interface.
+func (gossiper *gossiper) ExchangePeers(ctx context.Context) error {
+	addrs, err := gossiper.addrBook.Addrs(1)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+	return requestPeers(ctx, gossiper.addrBook, gossiper.client, gossiper.logger, gossiper.self, addrs[0])
+}
+
+// requestPeers from a remote `net.Addr` and insert the ones that are unknown
+// into the `addr.Book`, other than `self`, which can be nil. Whether or not the
+// remote `net.Addr` responded is recorded in the `addr.Book`.
+func requestPeers(ctx context.Context, addrBook addr.Book, client Client, logger logging.Logger, self, peer net.Addr) error {
+	peers, err := client.Peers(ctx, peer, MaxPeers)
+	if err != nil {
+		if err := addrBook.Failed(peer); err != nil {
+			logger.Error("cannot record failure", logging.Peer(peer), logging.Err(err))
+		}
+		return err
+	}
+	if err := addrBook.Seen(peer); err != nil {
+		logger.Error("cannot record response", logging.Peer(peer), logging.Err(err))
+	}
+
+	if len(peers) > MaxPeers {
+		peers = peers[:MaxPeers]
+	}
+	// When the `addr.Book` records sources, the peer is recorded as the source
+	// of every `net.Addr` that it returns
+	sourcedBook, sourced := addrBook.(addr.SourcedBook)
+	for _, addr := range peers {
+		if isSelf(self, addr) {
+			continue
+		}
+		if _, ok := addrBook.Record(addr); ok {
+			continue
+		}
+		if sourced {
+			if err := sourcedBook.InsertAddrFrom(addr, peer); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := addrBook.InsertAddr(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isSelf returns true if the `net.Addr` is `self`, which can be nil.
+func isSelf(self, addr net.Addr) bool {
+	return self != nil && self.String() == addr.String()
+}
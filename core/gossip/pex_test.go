@@ -0,0 +1,117 @@
+package gossip_test
+
+import (
+	"context"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+var _ = Describe("Peer exchange", func() {
+
+	init := func(n int) ([]addr.Book, []net.Addr, []Gossiper) {
+		books := make([]addr.Book, n)
+		peers := make([]net.Addr, n)
+		gossipers := make([]Gossiper, n)
+		client := testutils.NewMockClient()
+		for i := range gossipers {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			books[i] = book
+			peers[i] = testutils.RandomAddr()
+			gossipers[i] = NewGossiper(books[i], 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, testutils.NewMockMessages())
+			client.Connect(peers[i], gossipers[i])
+		}
+		return books, peers, gossipers
+	}
+
+	Context("when returning peers", func() {
+
+		It("should return at most the maximum number of peers", func() {
+			books, _, gossipers := init(1)
+			for i := 0; i < 2*MaxPeers; i++ {
+				Expect(books[0].InsertAddr(testutils.RandomAddr())).ShouldNot(HaveOccurred())
+			}
+
+			peers, err := gossipers[0].Peers(context.Background(), 2*MaxPeers)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(peers).Should(HaveLen(MaxPeers))
+
+			peers, err = gossipers[0].Peers(context.Background(), 1)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(peers).Should(HaveLen(1))
+		})
+	})
+
+	Context("when exchanging peers", func() {
+
+		It("should learn the peers that are known to the seed", func() {
+			books, peers, gossipers := init(4)
+			Expect(books[0].InsertAddr(peers[1])).ShouldNot(HaveOccurred())
+			for _, peer := range peers[2:] {
+				Expect(books[1].InsertAddr(peer)).ShouldNot(HaveOccurred())
+			}
+
+			Expect(gossipers[0].ExchangePeers(context.Background())).ShouldNot(HaveOccurred())
+			known, err := books[0].Addrs(len(peers))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(known).Should(ConsistOf(peers[1:]))
+		})
+
+		It("should not learn its own address", func() {
+			books, peers, gossipers := init(2)
+			for _, peer := range peers {
+				Expect(books[1].InsertAddr(peer)).ShouldNot(HaveOccurred())
+			}
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(book.InsertAddr(peers[1])).ShouldNot(HaveOccurred())
+			client := testutils.NewMockClient()
+			client.Connect(peers[1], gossipers[1])
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, testutils.NewMockMessages(), WithSelf(peers[0]))
+
+			Expect(gossiper.ExchangePeers(context.Background())).ShouldNot(HaveOccurred())
+			known, err := book.Addrs(len(peers))
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(known).Should(ConsistOf(peers[1]))
+		})
+
+		It("should do nothing when there are no peers", func() {
+			_, _, gossipers := init(1)
+			Expect(gossipers[0].ExchangePeers(context.Background())).ShouldNot(HaveOccurred())
+		})
+
+		It("should record a failure when the peer cannot be reached", func() {
+			books, _, gossipers := init(1)
+			unreachable := testutils.RandomAddr()
+			Expect(books[0].InsertAddr(unreachable)).ShouldNot(HaveOccurred())
+
+			Expect(gossipers[0].ExchangePeers(context.Background())).Should(HaveOccurred())
+			record, ok := books[0].Record(unreachable)
+			Expect(ok).Should(BeTrue())
+			Expect(record.Failures).Should(Equal(1))
+		})
+
+		It("should record the peer as the source of the peers that it returns", func() {
+			books, peers, _ := init(2)
+			book, err := addr.NewBucketedBook(testutils.NewMockAddrs(), nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			client := testutils.NewMockClient()
+			client.Connect(peers[1], NewGossiper(books[1], 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, testutils.NewMockMessages()))
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, testutils.NewMockMessages())
+			learned := testutils.RandomAddr()
+			Expect(book.InsertAddr(peers[1])).ShouldNot(HaveOccurred())
+			Expect(books[1].InsertAddr(learned)).ShouldNot(HaveOccurred())
+
+			Expect(gossiper.ExchangePeers(context.Background())).ShouldNot(HaveOccurred())
+			record, ok := book.Record(learned)
+			Expect(ok).Should(BeTrue())
+			Expect(record.Source).Should(Equal(peers[1].String()))
+		})
+	})
+})
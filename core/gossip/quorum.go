@@ -0,0 +1,106 @@
+package gossip
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/republicprotocol/babble-go/core/logging"
+	"github.com/republicprotocol/co-go"
+)
+
+// QuorumRetryInterval is the time that a quorum broadcast waits before it
+// retries peers that failed, after every other peer has been tried.
+const QuorumRetryInterval = 500 * time.Millisecond
+
+// ErrNoQuorum is returned when a quorum broadcast is not acknowledged by enough
+// peers before its context is done.
+var ErrNoQuorum = errors.New("message was not acknowledged by a quorum")
+
+// BroadcastQuorum implements the Gossiper interface.
+func (gossiper *gossiper) BroadcastQuorum(ctx context.Context, message Message, k int) (Report, error) {
+	report, err := gossiper.broadcastQuorum(ctx, message, k)
+	if err != nil {
+		gossiper.logger.Debug("cannot broadcast message to quorum", logging.Key(message.Key), logging.Nonce(message.Nonce), logging.Any("acknowledged", len(report.Acknowledged())), logging.Any("quorum", k), logging.Err(err))
+	}
+	return report, err
+}
+
+func (gossiper *gossiper) broadcastQuorum(ctx context.Context, message Message, k int) (Report, error) {
+	message, err := gossiper.sign(message)
+	if err != nil {
+		return Report{}, err
+	}
+	if err := gossiper.Start(); err != nil {
+		return Report{}, err
+	}
+	ctx, done, err := gossiper.bind(ctx)
+	if err != nil {
+		return Report{}, err
+	}
+	defer done()
+
+	report := Report{Deliveries: []Delivery{}}
+	acknowledged := []net.Addr{}
+	tried := []net.Addr{}
+	failed := map[string]bool{}
+	for len(acknowledged) < k {
+		addrs, err := gossiper.selector.SelectPeers(gossiper.addrBook, k-len(acknowledged), message, tried)
+		if err != nil {
+			return report, err
+		}
+
+		// Once every peer has been tried, peers that failed are retried
+		// after waiting, in case they have recovered or new peers have been
+		// learned
+		if len(addrs) == 0 {
+			select {
+			case <-ctx.Done():
+				return report, ErrNoQuorum
+			case <-time.After(QuorumRetryInterval):
+			}
+			tried = append([]net.Addr{}, acknowledged...)
+			continue
+		}
+
+		// Only the first failure of a peer is recorded in the `addr.Book`,
+		// so that retrying a peer does not evict it
+		deliveries := make([]Delivery, len(addrs))
+		co.ForAll(addrs, func(i int) {
+			ctx := ctx
+			if failed[addrs[i].String()] {
+				ctx = withRetry(ctx)
+			}
+			err := gossiper.outbound.Send(ctx, addrs[i], message)
+			deliveries[i] = Delivery{To: addrs[i], Err: err}
+		})
+		gossiper.logFailed(deliveries, message)
+		for _, delivery := range deliveries {
+			report.Deliveries = append(report.Deliveries, delivery)
+			tried = append(tried, delivery.To)
+			if delivery.Err == nil {
+				acknowledged = append(acknowledged, delivery.To)
+			} else {
+				failed[delivery.To.String()] = true
+			}
+		}
+		if len(acknowledged) < k && ctx.Err() != nil {
+			return report, ErrNoQuorum
+		}
+	}
+	return report, nil
+}
+
+type retryKey struct{}
+
+// withRetry returns a copy of the context that marks a send as a retry, whose
+// failure is not recorded in the `addr.Book`.
+func withRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, retryKey{}, true)
+}
+
+func isRetry(ctx context.Context) bool {
+	retry, _ := ctx.Value(retryKey{}).(bool)
+	return retry
+}
@@ -0,0 +1,109 @@
+package gossip_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+// recoveringClient fails to send Messages to a `net.Addr` until some time.
+type recoveringClient struct {
+	testutils.MockClient
+
+	down  net.Addr
+	until time.Time
+}
+
+func (client recoveringClient) Send(ctx context.Context, to net.Addr, message Message) error {
+	if to.String() == client.down.String() && time.Now().Before(client.until) {
+		return errors.New("cannot reach peer")
+	}
+	return client.MockClient.Send(ctx, to, message)
+}
+
+var _ = Describe("Quorum broadcast", func() {
+
+	init := func(client Client, n int) (Gossiper, []net.Addr) {
+		gossiper, _, peers := newGossiperWithPeers(client, 1, n)
+		return gossiper, peers
+	}
+
+	message := NewMessage(1, []byte("key"), []byte("value"), nil)
+
+	Context("when enough peers can be reached", func() {
+
+		It("should sample more peers until the quorum acknowledges", func() {
+			client := unreachableClient{testutils.NewMockClient(), map[string]bool{}}
+			gossiper, peers := init(client, 8)
+			for _, peer := range peers[:4] {
+				client.unreachable[peer.String()] = true
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			report, err := gossiper.BroadcastQuorum(ctx, message, 3)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Acknowledged()).Should(HaveLen(3))
+			confirmed := map[string]bool{}
+			for _, peer := range report.Acknowledged() {
+				Expect(client.unreachable[peer.String()]).Should(BeFalse())
+				Expect(client.Sent(peer)).Should(HaveLen(1))
+				confirmed[peer.String()] = true
+			}
+			Expect(confirmed).Should(HaveLen(3))
+		})
+
+		It("should retry peers that have recovered", func() {
+			client := &recoveringClient{testutils.NewMockClient(), nil, time.Now().Add(QuorumRetryInterval / 2)}
+			gossiper, peers := init(client, 2)
+			client.down = peers[0]
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			report, err := gossiper.BroadcastQuorum(ctx, message, 2)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Acknowledged()).Should(ConsistOf(peers))
+			Expect(report.Failed()).Should(HaveLen(1))
+		})
+	})
+
+	Context("when too few peers can be reached", func() {
+
+		It("should only count the first failure of a peer towards its eviction", func() {
+			client := unreachableClient{testutils.NewMockClient(), map[string]bool{}}
+			gossiper, book, peers := newGossiperWithPeers(client, 1, 2)
+			client.unreachable[peers[0].String()] = true
+
+			// The unreachable peer is retried more often than it can fail
+			// before it is evicted
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(addr.MaxFailures+1)*QuorumRetryInterval)
+			defer cancel()
+			_, err := gossiper.BroadcastQuorum(ctx, message, 2)
+			Expect(err).Should(Equal(ErrNoQuorum))
+			record, ok := book.Record(peers[0])
+			Expect(ok).Should(BeTrue())
+			Expect(record.Failures).Should(Equal(1))
+		})
+
+		It("should return an error when the context is done", func() {
+			client := unreachableClient{testutils.NewMockClient(), map[string]bool{}}
+			gossiper, peers := init(client, 3)
+			client.unreachable[peers[0].String()] = true
+			client.unreachable[peers[1].String()] = true
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			report, err := gossiper.BroadcastQuorum(ctx, message, 2)
+			Expect(err).Should(Equal(ErrNoQuorum))
+			Expect(report.Acknowledged()).Should(Equal([]net.Addr{peers[2]}))
+		})
+	})
+})
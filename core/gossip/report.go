@@ -0,0 +1,123 @@
+package gossip
+
+import (
+	"context"
+	"net"
+
+	"github.com/republicprotocol/babble-go/core/logging"
+	"github.com/republicprotocol/co-go"
+)
+
+// A Delivery is the result of sending a Message to one peer. The error is nil
+// if the peer acknowledged the Message.
+type Delivery struct {
+	To  net.Addr
+	Err error
+}
+
+// A Report of a broadcast holds the Delivery of the Message to every peer that
+// it was sent to.
+type Report struct {
+	Deliveries []Delivery
+}
+
+// Acknowledged returns the peers that acknowledged the Message.
+func (report Report) Acknowledged() []net.Addr {
+	addrs := make([]net.Addr, 0, len(report.Deliveries))
+	for _, delivery := range report.Deliveries {
+		if delivery.Err == nil {
+			addrs = append(addrs, delivery.To)
+		}
+	}
+	return addrs
+}
+
+// Failed returns the Delivery to every peer that did not acknowledge the
+// Message.
+func (report Report) Failed() []Delivery {
+	deliveries := make([]Delivery, 0)
+	for _, delivery := range report.Deliveries {
+		if delivery.Err != nil {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	return deliveries
+}
+
+// BroadcastSync implements the Gossiper interface.
+func (gossiper *gossiper) BroadcastSync(ctx context.Context, message Message) (Report, error) {
+	message, err := gossiper.sign(message)
+	if err != nil {
+		gossiper.logger.Error("cannot broadcast message", logging.Key(message.Key), logging.Nonce(message.Nonce), logging.Err(err))
+		return Report{}, err
+	}
+	addrs, err := gossiper.selector.SelectPeers(gossiper.addrBook, gossiper.α, message, nil)
+	if err != nil {
+		gossiper.logger.Error("cannot broadcast message", logging.Key(message.Key), logging.Nonce(message.Nonce), logging.Err(err))
+		return Report{}, err
+	}
+
+	if err := gossiper.Start(); err != nil {
+		gossiper.logger.Error("cannot broadcast message", logging.Key(message.Key), logging.Nonce(message.Nonce), logging.Err(err))
+		return Report{}, err
+	}
+	ctx, done, err := gossiper.bind(ctx)
+	if err != nil {
+		gossiper.logger.Error("cannot broadcast message", logging.Key(message.Key), logging.Nonce(message.Nonce), logging.Err(err))
+		return Report{}, err
+	}
+	defer done()
+
+	report := Report{Deliveries: make([]Delivery, len(addrs))}
+	co.ForAll(addrs, func(i int) {
+		err := gossiper.outbound.Send(ctx, addrs[i], message)
+		report.Deliveries[i] = Delivery{To: addrs[i], Err: err}
+	})
+	gossiper.logFailed(report.Deliveries, message)
+	return report, nil
+}
+
+// logFailed logs every Delivery that failed. Failures are also returned to the
+// caller in a Report, so they are only logged at the debug level.
+func (gossiper *gossiper) logFailed(deliveries []Delivery, message Message) {
+	for _, delivery := range deliveries {
+		if delivery.Err != nil {
+			gossiper.logger.Debug("cannot deliver message", logging.Peer(delivery.To), logging.Key(message.Key), logging.Nonce(message.Nonce), logging.Err(delivery.Err))
+		}
+	}
+}
+
+// bind the context of a synchronous broadcast to the lifetime of the
+// Gossiper. The returned context is cancelled when either the caller is done,
+// or the Gossiper is stopped, and the Gossiper waits for the returned function
+// to be called when it is stopped. It returns ErrStopped if the Gossiper is
+// stopped.
+func (gossiper *gossiper) bind(ctx context.Context) (context.Context, func(), error) {
+	lifetime, done, err := gossiper.lifecycle.begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-lifetime.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		cancel()
+		done()
+	}, nil
+}
+
+// sign the Payload of a Message with the Signer of the Gossiper.
+func (gossiper *gossiper) sign(message Message) (Message, error) {
+	signature, err := gossiper.signer.Sign(message.Payload())
+	if err != nil {
+		return message, err
+	}
+	message.Scheme = gossiper.signer.Scheme()
+	message.Signature = signature
+	return message, nil
+}
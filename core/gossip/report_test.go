@@ -0,0 +1,105 @@
+package gossip_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+// unreachableClient fails to send Messages to the unreachable `net.Addr`.
+type unreachableClient struct {
+	testutils.MockClient
+
+	unreachable map[string]bool
+}
+
+func (client unreachableClient) Send(ctx context.Context, to net.Addr, message Message) error {
+	if client.unreachable[to.String()] {
+		return errors.New("cannot reach peer")
+	}
+	return client.MockClient.Send(ctx, to, message)
+}
+
+// newGossiperWithPeers returns a Gossiper that sends to α peers, and whose
+// `addr.Book` holds n peers.
+func newGossiperWithPeers(client Client, α, n int) (Gossiper, addr.Book, []net.Addr) {
+	book, err := addr.NewBook(testutils.NewMockAddrs())
+	Expect(err).ShouldNot(HaveOccurred())
+	peers := make([]net.Addr, n)
+	for i := range peers {
+		peers[i] = testutils.RandomAddr()
+		Expect(book.InsertAddr(peers[i])).ShouldNot(HaveOccurred())
+	}
+	gossiper := NewGossiper(book, α, testutils.MockSinger{}, testutils.MockVerifier{}, nil, nil, nil, client, testutils.NewMockMessages())
+	return gossiper, book, peers
+}
+
+var _ = Describe("Synchronous broadcast", func() {
+
+	init := func(client Client, n int) (Gossiper, []net.Addr) {
+		gossiper, _, peers := newGossiperWithPeers(client, n, n)
+		return gossiper, peers
+	}
+
+	message := NewMessage(1, []byte("key"), []byte("value"), nil)
+
+	Context("when some peers cannot be reached", func() {
+
+		It("should report which peers acknowledged and which failed", func() {
+			client := unreachableClient{testutils.NewMockClient(), map[string]bool{}}
+			gossiper, peers := init(client, 4)
+			client.unreachable[peers[0].String()] = true
+
+			report, err := gossiper.BroadcastSync(context.Background(), message)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Deliveries).Should(HaveLen(4))
+			Expect(report.Acknowledged()).Should(ConsistOf(peers[1:]))
+			Expect(report.Failed()).Should(HaveLen(1))
+			Expect(report.Failed()[0].To).Should(Equal(peers[0]))
+			Expect(report.Failed()[0].Err).Should(MatchError("cannot reach peer"))
+
+			// Every acknowledged peer has already received the signed
+			// Message when the broadcast returns
+			for _, peer := range peers[1:] {
+				Expect(client.Sent(peer)).Should(HaveLen(1))
+				Expect(client.Sent(peer)[0].Signature).Should(Equal(message.Payload()))
+			}
+		})
+	})
+
+	Context("when the context is done", func() {
+
+		It("should return with every send cancelled", func() {
+			client := blockingClient{testutils.NewMockClient(), make(chan net.Addr, 2)}
+			gossiper, _ := init(client, 2)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			report, err := gossiper.BroadcastSync(ctx, message)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(report.Acknowledged()).Should(BeEmpty())
+			for _, delivery := range report.Failed() {
+				Expect(delivery.Err).Should(Equal(context.DeadlineExceeded))
+			}
+		})
+	})
+
+	Context("when the gossiper is stopped", func() {
+
+		It("should return an error", func() {
+			gossiper, _ := init(testutils.NewMockClient(), 1)
+			Expect(gossiper.Stop(context.Background())).ShouldNot(HaveOccurred())
+
+			_, err := gossiper.BroadcastSync(context.Background(), message)
+			Expect(err).Should(Equal(ErrStopped))
+		})
+	})
+})
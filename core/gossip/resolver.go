@@ -0,0 +1,46 @@
+package gossip
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// A Resolver resolves conflicts between two different Messages that have the
+// same `Key` and the same `Nonce`. It must be deterministic, and must not
+// depend on the order in which the Messages were received, so that all
+// Gossipers converge on the same Message.
+type Resolver interface {
+
+	// Resolve returns true if the `message` should replace the
+	// `previousMessage`, and false if the `previousMessage` should be kept.
+	// It must return false when both Messages have the same `Payload`.
+	Resolve(previousMessage, message Message) bool
+}
+
+type hashResolver struct{}
+
+// NewHashResolver returns a Resolver that keeps the Message with the higher
+// Keccak256 hash of its `Payload`.
+func NewHashResolver() Resolver {
+	return hashResolver{}
+}
+
+// Resolve implements the Resolver interface.
+func (hashResolver) Resolve(previousMessage, message Message) bool {
+	return bytes.Compare(crypto.Keccak256(message.Payload()), crypto.Keccak256(previousMessage.Payload())) > 0
+}
+
+// IsNewer returns true if the `message` should replace the `previousMessage`
+// with the same `Key`. This is the case when the `message` has a higher
+// `Nonce`, or when it has the same non-zero `Nonce` and the `resolver` prefers
+// it. A nil `resolver` never replaces a Message with the same `Nonce`.
+func IsNewer(resolver Resolver, previousMessage, message Message) bool {
+	if previousMessage.Nonce != message.Nonce {
+		return previousMessage.Nonce < message.Nonce
+	}
+	if previousMessage.Nonce == 0 || resolver == nil {
+		return false
+	}
+	return resolver.Resolve(previousMessage, message)
+}
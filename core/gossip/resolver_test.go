@@ -0,0 +1,131 @@
+package gossip_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+// recordingObserver records the Messages that it is notified about.
+type recordingObserver struct {
+	mu       *sync.Mutex
+	messages *[]Message
+}
+
+func newRecordingObserver() recordingObserver {
+	return recordingObserver{
+		mu:       new(sync.Mutex),
+		messages: new([]Message),
+	}
+}
+
+func (observer recordingObserver) Notify(message Message) error {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	*observer.messages = append(*observer.messages, message)
+	return nil
+}
+
+func (observer recordingObserver) Notified() []Message {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	return append([]Message{}, *observer.messages...)
+}
+
+var _ = Describe("Resolver", func() {
+
+	Context("when comparing messages", func() {
+
+		It("should prefer the message with the higher nonce", func() {
+			older := signedMessage("owner", 1, "key", "value")
+			newer := signedMessage("owner", 2, "key", "value")
+			Expect(IsNewer(NewHashResolver(), older, newer)).Should(BeTrue())
+			Expect(IsNewer(NewHashResolver(), newer, older)).Should(BeFalse())
+			Expect(IsNewer(nil, Message{}, older)).Should(BeTrue())
+		})
+
+		It("should prefer exactly one of two conflicting messages", func() {
+			message := signedMessage("owner", 1, "key", "value")
+			conflict := signedMessage("owner", 1, "key", "another value")
+			Expect(IsNewer(NewHashResolver(), message, conflict)).ShouldNot(Equal(IsNewer(NewHashResolver(), conflict, message)))
+			Expect(IsNewer(nil, message, conflict)).Should(BeFalse())
+			Expect(IsNewer(nil, conflict, message)).Should(BeFalse())
+		})
+
+		It("should not prefer a message with the same payload", func() {
+			message := signedMessage("owner", 1, "key", "value")
+			Expect(IsNewer(NewHashResolver(), message, message)).Should(BeFalse())
+		})
+	})
+
+	Context("when receiving conflicting messages", func() {
+
+		It("should converge on the same message regardless of the order", func() {
+			message := signedMessage("owner", 1, "key", "value")
+			conflict := signedMessage("owner", 1, "key", "another value")
+			expected := message
+			if NewHashResolver().Resolve(message, conflict) {
+				expected = conflict
+			}
+
+			for _, order := range [][]Message{{message, conflict}, {conflict, message}} {
+				book, err := addr.NewBook(testutils.NewMockAddrs())
+				Expect(err).ShouldNot(HaveOccurred())
+				messages := testutils.NewMockMessages()
+				observer := newRecordingObserver()
+				gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, observer, testutils.NewMockClient(), messages)
+
+				for _, message := range order {
+					Expect(gossiper.Receive(context.Background(), message)).ShouldNot(HaveOccurred())
+				}
+
+				stored, err := messages.Message(message.Key)
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(stored).Should(Equal(expected))
+				Expect(observer.Notified()[len(observer.Notified())-1]).Should(Equal(expected))
+			}
+		})
+
+		It("should notify the observer when switching to the conflicting message", func() {
+			message := signedMessage("owner", 1, "key", "value")
+			conflict := signedMessage("owner", 1, "key", "another value")
+			if NewHashResolver().Resolve(conflict, message) {
+				message, conflict = conflict, message
+			}
+
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			observer := newRecordingObserver()
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, observer, testutils.NewMockClient(), testutils.NewMockMessages())
+
+			Expect(gossiper.Receive(context.Background(), message)).ShouldNot(HaveOccurred())
+			Expect(gossiper.Receive(context.Background(), conflict)).ShouldNot(HaveOccurred())
+			Expect(gossiper.Receive(context.Background(), message)).ShouldNot(HaveOccurred())
+			Expect(observer.Notified()).Should(Equal([]Message{message, conflict}))
+		})
+
+		It("should not accept a conflicting message from a different owner", func() {
+			message := signedMessage("owner", 1, "key", "value")
+			// Pick a conflicting message that would be preferred if it was
+			// signed by the owner.
+			conflict := signedMessage("attacker", 1, "key", "another value")
+			for i := 0; !NewHashResolver().Resolve(message, conflict); i++ {
+				conflict = signedMessage("attacker", 1, "key", fmt.Sprintf("another value %v", i))
+			}
+
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, testutils.NewMockClient(), testutils.NewMockMessages())
+
+			Expect(gossiper.Receive(context.Background(), message)).ShouldNot(HaveOccurred())
+			Expect(gossiper.Receive(context.Background(), conflict)).Should(Equal(ErrNotOwner))
+		})
+	})
+})
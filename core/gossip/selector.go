@@ -0,0 +1,54 @@
+package gossip
+
+import (
+	"net"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+)
+
+// A PeerSelector selects the `net.Addr` that a Message is sent to when it is
+// broadcast, or forwarded after it is received.
+type PeerSelector interface {
+
+	// SelectPeers returns at most α `net.Addr` from the `addr.Book` to send
+	// the Message to. None of the returned `net.Addr` are in the `exclude`
+	// set, which holds the peers that are known to have the Message already,
+	// such as the peer that sent it.
+	SelectPeers(addrBook addr.Book, α int, message Message, exclude []net.Addr) ([]net.Addr, error)
+}
+
+type randomSelector struct {
+}
+
+// NewRandomSelector returns a PeerSelector that selects a uniformly random
+// sample of the `net.Addr` in the `addr.Book`, other than the excluded ones.
+func NewRandomSelector() PeerSelector {
+	return randomSelector{}
+}
+
+// SelectPeers implements the PeerSelector interface. It samples enough extra
+// `net.Addr` to replace any that are excluded.
+func (selector randomSelector) SelectPeers(addrBook addr.Book, α int, message Message, exclude []net.Addr) ([]net.Addr, error) {
+	addrs, err := addrBook.Addrs(α + len(exclude))
+	if err != nil {
+		return nil, err
+	}
+	if len(exclude) == 0 {
+		return addrs, nil
+	}
+
+	excluded := make(map[string]struct{}, len(exclude))
+	for _, addr := range exclude {
+		excluded[addr.String()] = struct{}{}
+	}
+	selected := make([]net.Addr, 0, α)
+	for _, addr := range addrs {
+		if len(selected) >= α {
+			break
+		}
+		if _, ok := excluded[addr.String()]; !ok {
+			selected = append(selected, addr)
+		}
+	}
+	return selected, nil
+}
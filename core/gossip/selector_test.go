@@ -0,0 +1,113 @@
+package gossip_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+// recordingSelector records the exclusion sets that it is given, and selects
+// peers randomly.
+type recordingSelector struct {
+	mu       *sync.Mutex
+	excluded *[][]net.Addr
+}
+
+func (selector recordingSelector) SelectPeers(addrBook addr.Book, α int, message Message, exclude []net.Addr) ([]net.Addr, error) {
+	selector.mu.Lock()
+	*selector.excluded = append(*selector.excluded, exclude)
+	selector.mu.Unlock()
+
+	return NewRandomSelector().SelectPeers(addrBook, α, message, exclude)
+}
+
+var _ = Describe("Peer selection", func() {
+
+	signedMessage := func(nonce uint64) Message {
+		message := NewMessage(nonce, []byte("key"), []byte("value"), nil)
+		message.Signature = message.Payload()
+		message.Scheme = testutils.MockScheme
+		return message
+	}
+
+	Context("when selecting random peers", func() {
+
+		It("should never select excluded peers", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			addrs := make([]net.Addr, 4)
+			for i := range addrs {
+				addrs[i] = testutils.RandomAddr()
+				Expect(book.InsertAddr(addrs[i])).ShouldNot(HaveOccurred())
+			}
+
+			for i := 0; i < 100; i++ {
+				selected, err := NewRandomSelector().SelectPeers(book, 2, Message{}, addrs[:2])
+				Expect(err).ShouldNot(HaveOccurred())
+				Expect(selected).Should(ConsistOf(addrs[2], addrs[3]))
+			}
+		})
+	})
+
+	Context("when forwarding a received message", func() {
+
+		It("should not forward the message back to the sender", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			sender, other := testutils.RandomAddr(), testutils.RandomAddr()
+			Expect(book.InsertAddr(sender)).ShouldNot(HaveOccurred())
+			Expect(book.InsertAddr(other)).ShouldNot(HaveOccurred())
+			client := testutils.NewMockClient()
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, testutils.NewMockMessages())
+
+			for nonce := uint64(1); nonce <= 20; nonce++ {
+				Expect(gossiper.Receive(WithSender(context.Background(), sender), signedMessage(nonce))).ShouldNot(HaveOccurred())
+			}
+			Eventually(func() int { return len(client.Sent(other)) }, time.Second).Should(Equal(20))
+			Expect(client.Sent(sender)).Should(BeEmpty())
+		})
+
+		It("should still forward the message to other peers on the host of the sender", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			sender := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 18514}
+			sameHost := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 18515}
+			Expect(book.InsertAddr(sender)).ShouldNot(HaveOccurred())
+			Expect(book.InsertAddr(sameHost)).ShouldNot(HaveOccurred())
+			client := testutils.NewMockClient()
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, testutils.NewMockMessages())
+
+			for nonce := uint64(1); nonce <= 20; nonce++ {
+				Expect(gossiper.Receive(WithSender(context.Background(), sender), signedMessage(nonce))).ShouldNot(HaveOccurred())
+			}
+			Eventually(func() int { return len(client.Sent(sameHost)) }, time.Second).Should(Equal(20))
+			Expect(client.Sent(sender)).Should(BeEmpty())
+
+			// A sender that does not advertise its address excludes nothing
+			for nonce := uint64(21); nonce <= 40; nonce++ {
+				Expect(gossiper.Receive(context.Background(), signedMessage(nonce))).ShouldNot(HaveOccurred())
+			}
+			Eventually(func() int { return len(client.Sent(sender)) + len(client.Sent(sameHost)) }, time.Second).Should(Equal(40))
+		})
+
+		It("should give the sender to the peer selector", func() {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			sender := testutils.RandomAddr()
+			selector := recordingSelector{new(sync.Mutex), new([][]net.Addr)}
+			gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, testutils.NewMockClient(), testutils.NewMockMessages(), WithSelector(selector))
+
+			Expect(gossiper.Receive(WithSender(context.Background(), sender), signedMessage(1))).ShouldNot(HaveOccurred())
+			Expect(gossiper.Broadcast(context.Background(), NewMessage(2, []byte("key"), []byte("value"), nil))).ShouldNot(HaveOccurred())
+			Expect(*selector.excluded).Should(Equal([][]net.Addr{{sender}, nil}))
+		})
+	})
+})
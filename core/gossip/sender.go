@@ -0,0 +1,69 @@
+package gossip
+
+import (
+	"context"
+	"net"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/core/logging"
+)
+
+type senderKey struct{}
+
+// WithSender returns a copy of the context that carries the `net.Addr` at which
+// the sender of a Message can be reached. Servers use it to tell a Gossiper
+// where a Message came from.
+func WithSender(ctx context.Context, sender net.Addr) context.Context {
+	return context.WithValue(ctx, senderKey{}, sender)
+}
+
+// SenderFromContext returns the `net.Addr` of the sender of a Message, and
+// false if the context does not carry one.
+func SenderFromContext(ctx context.Context) (net.Addr, bool) {
+	sender, ok := ctx.Value(senderKey{}).(net.Addr)
+	return sender, ok && sender != nil
+}
+
+type learningServer struct {
+	Server
+
+	addrBook addr.Book
+	logger   logging.Logger
+}
+
+// NewLearningServer returns a Server that inserts the sender of every valid
+// Message it receives into the `addr.Book`, if the sender is not already in
+// it, so that inbound connections grow the topology as well as outbound ones.
+// The sender is read from the context using SenderFromContext. All requests
+// are delegated to the `server`. The `logger` can be nil, in which case the
+// default `logging.Logger` is used.
+func NewLearningServer(server Server, addrBook addr.Book, logger logging.Logger) Server {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	return &learningServer{
+		Server: server,
+
+		addrBook: addrBook,
+		logger:   logger,
+	}
+}
+
+// Receive implements the Server interface.
+func (server *learningServer) Receive(ctx context.Context, message Message) error {
+	if err := server.Server.Receive(ctx, message); err != nil {
+		return err
+	}
+
+	sender, ok := SenderFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if _, ok := server.addrBook.Record(sender); ok {
+		return nil
+	}
+	if err := server.addrBook.InsertAddr(sender); err != nil {
+		server.logger.Error("cannot insert sender", logging.Peer(sender), logging.Err(err))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package gossip_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+var _ = Describe("Learning server", func() {
+
+	init := func() (Server, addr.Book) {
+		book, err := addr.NewBook(testutils.NewMockAddrs())
+		Expect(err).ShouldNot(HaveOccurred())
+		gossiper := NewGossiper(book, 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, testutils.NewMockClient(), testutils.NewMockMessages())
+		return NewLearningServer(gossiper, book, nil), book
+	}
+
+	Context("when receiving a message", func() {
+
+		It("should insert the sender into the address book", func() {
+			server, book := init()
+			sender := testutils.RandomAddr()
+			message := NewMessage(1, []byte("key"), []byte("value"), nil)
+			message.Signature = message.Payload()
+			message.Scheme = testutils.MockScheme
+
+			Expect(server.Receive(WithSender(context.Background(), sender), message)).ShouldNot(HaveOccurred())
+			_, ok := book.Record(sender)
+			Expect(ok).Should(BeTrue())
+		})
+
+		It("should not insert the sender of an invalid message", func() {
+			server, book := init()
+			sender := testutils.RandomAddr()
+			message := NewMessage(1, []byte("key"), []byte("value"), []byte("invalid signature"))
+
+			Expect(server.Receive(WithSender(context.Background(), sender), message)).Should(HaveOccurred())
+			_, ok := book.Record(sender)
+			Expect(ok).Should(BeFalse())
+		})
+
+		It("should do nothing when the sender is not known", func() {
+			server, book := init()
+			message := NewMessage(1, []byte("key"), []byte("value"), nil)
+			message.Signature = message.Payload()
+			message.Scheme = testutils.MockScheme
+
+			Expect(server.Receive(context.Background(), message)).ShouldNot(HaveOccurred())
+			addrs, err := book.Addrs(1)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrs).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,245 @@
+package gossip
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/republicprotocol/babble-go/core/logging"
+)
+
+// A Digest summarises a Message by its `Key`, its `Nonce`, and the Keccak256
+// hash of its `Payload`. Digests are exchanged during anti-entropy to find the
+// Messages that differ between two nodes, without sending the Messages
+// themselves. The hash finds conflicting Messages that have the same `Nonce`,
+// so that their conflict can be resolved by a Resolver. Only the `Payload` is
+// hashed, because a Resolver considers Messages with the same `Payload` to be
+// the same Message.
+type Digest struct {
+	Key   []byte `json:"key"`
+	Nonce uint64 `json:"nonce"`
+	Hash  []byte `json:"hash"`
+}
+
+// NewDigest returns the Digest of a Message.
+func NewDigest(message Message) Digest {
+	return Digest{
+		Key:   message.Key,
+		Nonce: message.Nonce,
+		Hash:  crypto.Keccak256(message.Payload()),
+	}
+}
+
+// conflicts returns true if the Digest is of a different Message with the same
+// `Nonce`. A Digest without a hash never conflicts, so that Digests from nodes
+// that do not send hashes are only compared by their `Nonce`.
+func (digest Digest) conflicts(message Message) bool {
+	if message.Nonce != digest.Nonce || len(digest.Hash) == 0 {
+		return false
+	}
+	return !bytes.Equal(digest.Hash, crypto.Keccak256(message.Payload()))
+}
+
+// RunAntiEntropy calls `Synchronise` on the Gossiper once every period until
+// the context is done. Errors are logged by the `logger`, and do not stop
+// future rounds. The `logger` can be nil, in which case the default
+// `logging.Logger` is used.
+func RunAntiEntropy(ctx context.Context, gossiper Gossiper, period time.Duration, logger logging.Logger) {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := gossiper.Synchronise(ctx); err != nil {
+			logger.Debug("cannot synchronise", logging.Err(err))
+		}
+	}
+}
+
+// Sync implements the Server interface. When the Digest of a Message conflicts
+// with the local Message, the local Message is returned and the Digest is
+// wanted, so that both nodes can resolve the conflict.
+func (gossiper *gossiper) Sync(ctx context.Context, path []byte, digests []Digest) ([]Message, []Digest, error) {
+	messages, err := gossiper.messagesInPath(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	messagesByKey := make(map[string]Message, len(messages))
+	for _, message := range messages {
+		messagesByKey[string(message.Key)] = message
+	}
+
+	newer := make([]Message, 0)
+	wanted := make([]Digest, 0)
+	for _, digest := range digests {
+		if !inPath(crypto.Keccak256(digest.Key), path) {
+			continue
+		}
+		message, ok := messagesByKey[string(digest.Key)]
+		delete(messagesByKey, string(digest.Key))
+		if !ok || message.Nonce < digest.Nonce {
+			wanted = append(wanted, digest)
+			continue
+		}
+		if message.Nonce > digest.Nonce {
+			newer = append(newer, message)
+			continue
+		}
+		if digest.conflicts(message) {
+			newer = append(newer, message)
+			wanted = append(wanted, digest)
+		}
+	}
+	for _, message := range messagesByKey {
+		newer = append(newer, message)
+	}
+
+	return newer, wanted, nil
+}
+
+// Hashes implements the Server interface.
+func (gossiper *gossiper) Hashes(ctx context.Context, path []byte) ([][]byte, error) {
+	tree, ok := gossiper.messages.(Tree)
+	if !ok {
+		return nil, ErrNoTree
+	}
+	return tree.Hashes(path)
+}
+
+// Synchronise implements the Gossiper interface.
+func (gossiper *gossiper) Synchronise(ctx context.Context) error {
+	addrs, err := gossiper.addrBook.Addrs(1)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+
+	if tree, ok := gossiper.messages.(Tree); ok {
+		return gossiper.synchroniseTree(ctx, addrs[0], tree, []byte{})
+	}
+	return gossiper.synchronisePath(ctx, addrs[0], []byte{})
+}
+
+// synchroniseTree compares the children of the node at the path with the
+// children of the same node in the Merkle tree of the peer, and descends into
+// the children that are different. Once a bucket is reached, the Messages in
+// the bucket are synchronised. If the peer cannot return its Merkle tree,
+// then all Messages in the subtree are synchronised instead.
+func (gossiper *gossiper) synchroniseTree(ctx context.Context, peer net.Addr, tree Tree, path []byte) error {
+	if len(path) >= TreeDepth {
+		return gossiper.synchronisePath(ctx, peer, path)
+	}
+
+	remoteHashes, err := gossiper.client.Hashes(ctx, peer, path)
+	if err != nil {
+		gossiper.logger.Debug("cannot get merkle tree", logging.Peer(peer), logging.Err(err))
+		return gossiper.synchronisePath(ctx, peer, path)
+	}
+	hashes, err := tree.Hashes(path)
+	if err != nil {
+		return err
+	}
+	if len(remoteHashes) != len(hashes) {
+		return fmt.Errorf("expected %v hashes from %v, got %v", len(hashes), peer.String(), len(remoteHashes))
+	}
+
+	for i := range hashes {
+		if bytes.Equal(hashes[i], remoteHashes[i]) {
+			continue
+		}
+		if err := gossiper.synchroniseTree(ctx, peer, tree, append(append([]byte{}, path...), byte(i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// synchronisePath exchanges the Digests of all Messages in the subtree of the
+// node at the path with the peer, and then pulls and pushes the Messages that
+// differ.
+func (gossiper *gossiper) synchronisePath(ctx context.Context, peer net.Addr, path []byte) error {
+	messages, err := gossiper.messagesInPath(path)
+	if err != nil {
+		return err
+	}
+	digests := make([]Digest, len(messages))
+	for i := range messages {
+		digests[i] = NewDigest(messages[i])
+	}
+
+	pulled, wanted, err := gossiper.client.Sync(ctx, peer, path, digests)
+	if err != nil {
+		return err
+	}
+
+	// Pulled Messages are not forwarded because they are only missing from
+	// this node, and forwarding them would flood the network with old
+	// Messages
+	for _, message := range pulled {
+		if err := gossiper.receive(ctx, message, false); err != nil {
+			gossiper.logger.Warn("cannot receive pulled message", logging.Peer(peer), logging.Key(message.Key), logging.Nonce(message.Nonce), logging.Err(err))
+		}
+	}
+	for _, digest := range wanted {
+		message, err := gossiper.messages.Message(digest.Key)
+		if err != nil {
+			return err
+		}
+		if message.Nonce == 0 {
+			continue
+		}
+		if err := gossiper.send(ctx, peer, message); err != nil {
+			gossiper.logger.Debug("cannot push message", logging.Peer(peer), logging.Key(message.Key), logging.Nonce(message.Nonce), logging.Err(err))
+		}
+	}
+
+	return nil
+}
+
+// messagesInPath returns all Messages in the subtree of the node at the path.
+// Without a Tree, this requires reading all Messages.
+func (gossiper *gossiper) messagesInPath(path []byte) ([]Message, error) {
+	if len(path) == 0 {
+		return gossiper.messages.Messages()
+	}
+
+	if tree, ok := gossiper.messages.(Tree); ok {
+		digests, err := tree.Digests(path)
+		if err != nil {
+			return nil, err
+		}
+		messages := make([]Message, 0, len(digests))
+		for _, digest := range digests {
+			message, err := tree.Message(digest.Key)
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, message)
+		}
+		return messages, nil
+	}
+
+	allMessages, err := gossiper.messages.Messages()
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]Message, 0)
+	for _, message := range allMessages {
+		if inPath(crypto.Keccak256(message.Key), path) {
+			messages = append(messages, message)
+		}
+	}
+	return messages, nil
+}
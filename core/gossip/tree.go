@@ -0,0 +1,259 @@
+package gossip
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// TreeDepth is the number of levels in the Merkle tree maintained by a Tree.
+// Each level consumes one nibble of the Keccak256 hash of a Message `Key`, so
+// the leaves of the tree are 16^TreeDepth buckets. All nodes in the network
+// must use the same depth for their Merkle trees to be comparable.
+const TreeDepth = 6
+
+// TreeWidth is the number of children of every node in the Merkle tree.
+const TreeWidth = 16
+
+// ErrNoTree is returned when the Merkle tree of a node is requested, but its
+// Messages are not stored in a Tree.
+var ErrNoTree = errors.New("messages are not stored in a merkle tree")
+
+// A Tree is a Messages store that also maintains a Merkle tree over the
+// Digests of its Messages. The Merkle tree is a prefix trie over the Keccak256
+// hash of each `Key`, where each level consumes one nibble of the hash. A node
+// is identified by a path of nibbles from the root, and the root is identified
+// by the empty path.
+//
+// When the Messages of a Gossiper are stored in a Tree, anti-entropy compares
+// the Merkle trees of two nodes and only descends into the subtrees that
+// differ. This finds the differing Messages in O(diff · log n) round trips,
+// instead of sending the Digests of all Messages.
+type Tree interface {
+	Messages
+
+	// Hashes returns the hashes of the children of the node at the path. The
+	// hash of an empty child is nil. The path must be shorter than the
+	// TreeDepth.
+	Hashes(path []byte) ([][]byte, error)
+
+	// Digests returns the Digests of all Messages in the subtree of the node
+	// at the path.
+	Digests(path []byte) ([]Digest, error)
+}
+
+type tree struct {
+	messages Messages
+
+	mu      *sync.RWMutex
+	leaves  map[string]leaf
+	buckets map[string]map[string]struct{}
+	nodes   map[string][]byte
+}
+
+type leaf struct {
+	digest Digest
+	hash   []byte
+}
+
+// NewTree returns a Tree that stores Messages in the underlying `messages`
+// store. All existing Messages are loaded into the Merkle tree. Afterwards,
+// Messages must only be inserted through the Tree, otherwise the Merkle tree
+// will not include them.
+func NewTree(messages Messages) (Tree, error) {
+	existingMessages, err := messages.Messages()
+	if err != nil {
+		return nil, err
+	}
+
+	tree := &tree{
+		messages: messages,
+
+		mu:      new(sync.RWMutex),
+		leaves:  map[string]leaf{},
+		buckets: map[string]map[string]struct{}{},
+		nodes:   map[string][]byte{},
+	}
+	for _, message := range existingMessages {
+		tree.insert(message)
+	}
+	return tree, nil
+}
+
+// InsertMessage implements the Messages interface.
+func (tree *tree) InsertMessage(message Message) error {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+
+	if err := tree.messages.InsertMessage(message); err != nil {
+		return err
+	}
+	tree.insert(message)
+	return nil
+}
+
+// InsertMessageIfNewer implements the Messages interface.
+func (tree *tree) InsertMessageIfNewer(message Message, resolver Resolver) (bool, error) {
+	tree.mu.Lock()
+	defer tree.mu.Unlock()
+
+	ok, err := tree.messages.InsertMessageIfNewer(message, resolver)
+	if err != nil || !ok {
+		return ok, err
+	}
+	tree.insert(message)
+	return true, nil
+}
+
+// Message implements the Messages interface.
+func (tree *tree) Message(key []byte) (Message, error) {
+	return tree.messages.Message(key)
+}
+
+// Messages implements the Messages interface.
+func (tree *tree) Messages() ([]Message, error) {
+	return tree.messages.Messages()
+}
+
+// MessagesWithPrefix implements the Messages interface.
+func (tree *tree) MessagesWithPrefix(prefix, cursor []byte, limit int) ([]Message, []byte, error) {
+	return tree.messages.MessagesWithPrefix(prefix, cursor, limit)
+}
+
+// Hashes implements the Tree interface.
+func (tree *tree) Hashes(path []byte) ([][]byte, error) {
+	if err := validatePath(path); err != nil {
+		return nil, err
+	}
+	if len(path) >= TreeDepth {
+		return nil, fmt.Errorf("expected path shorter than %v, got %v", TreeDepth, len(path))
+	}
+
+	tree.mu.RLock()
+	defer tree.mu.RUnlock()
+
+	return tree.children(path), nil
+}
+
+// Digests implements the Tree interface.
+func (tree *tree) Digests(path []byte) ([]Digest, error) {
+	if err := validatePath(path); err != nil {
+		return nil, err
+	}
+
+	tree.mu.RLock()
+	defer tree.mu.RUnlock()
+
+	digests := make([]Digest, 0)
+	if len(path) >= TreeDepth {
+		for keyHash := range tree.buckets[string(path[:TreeDepth])] {
+			if inPath([]byte(keyHash), path) {
+				digests = append(digests, tree.leaves[keyHash].digest)
+			}
+		}
+		return digests, nil
+	}
+	for keyHash, leaf := range tree.leaves {
+		if inPath([]byte(keyHash), path) {
+			digests = append(digests, leaf.digest)
+		}
+	}
+	return digests, nil
+}
+
+func (tree *tree) insert(message Message) {
+	keyHash := crypto.Keccak256(message.Key)
+	digest := NewDigest(message)
+	tree.leaves[string(keyHash)] = leaf{
+		digest: digest,
+		hash:   crypto.Keccak256(keyHash, appendUint64(nil, digest.Nonce), digest.Hash),
+	}
+
+	path := pathOf(keyHash)
+	if tree.buckets[string(path)] == nil {
+		tree.buckets[string(path)] = map[string]struct{}{}
+	}
+	tree.buckets[string(path)][string(keyHash)] = struct{}{}
+
+	tree.nodes[string(path)] = tree.bucketHash(path)
+	for depth := TreeDepth - 1; depth >= 0; depth-- {
+		tree.nodes[string(path[:depth])] = nodeHash(tree.children(path[:depth]))
+	}
+}
+
+// bucketHash returns the hash of the leaves in a bucket, sorted by the hash of
+// their keys.
+func (tree *tree) bucketHash(path []byte) []byte {
+	keyHashes := make([]string, 0, len(tree.buckets[string(path)]))
+	for keyHash := range tree.buckets[string(path)] {
+		keyHashes = append(keyHashes, keyHash)
+	}
+	sort.Strings(keyHashes)
+
+	hashes := make([][]byte, len(keyHashes))
+	for i, keyHash := range keyHashes {
+		hashes[i] = tree.leaves[keyHash].hash
+	}
+	return crypto.Keccak256(hashes...)
+}
+
+func (tree *tree) children(path []byte) [][]byte {
+	hashes := make([][]byte, TreeWidth)
+	for i := range hashes {
+		hashes[i] = tree.nodes[string(append(append([]byte{}, path...), byte(i)))]
+	}
+	return hashes
+}
+
+// nodeHash returns the hash of a node from the hashes of its children. Empty
+// children are hashed as zeroes, so that the position of every child is
+// committed to by the hash.
+func nodeHash(children [][]byte) []byte {
+	data := make([]byte, 0, 32*len(children))
+	for _, child := range children {
+		if child == nil {
+			child = make([]byte, 32)
+		}
+		data = append(data, child...)
+	}
+	return crypto.Keccak256(data)
+}
+
+// pathOf returns the path of the bucket that contains the hash of a key.
+func pathOf(keyHash []byte) []byte {
+	path := make([]byte, TreeDepth)
+	for i := range path {
+		path[i] = nibble(keyHash, i)
+	}
+	return path
+}
+
+// inPath returns true if the hash of a key is in the subtree of the node at
+// the path.
+func inPath(keyHash []byte, path []byte) bool {
+	for i := range path {
+		if i >= 2*len(keyHash) || nibble(keyHash, i) != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func nibble(data []byte, i int) byte {
+	if i%2 == 0 {
+		return data[i/2] >> 4
+	}
+	return data[i/2] & 0x0F
+}
+
+func validatePath(path []byte) error {
+	for _, n := range path {
+		if n >= TreeWidth {
+			return fmt.Errorf("expected path of nibbles, got %v", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package gossip_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/gossip"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+// countingClient counts the Digests that are sent during anti-entropy.
+type countingClient struct {
+	testutils.MockClient
+	digests *int64
+}
+
+func (client countingClient) Sync(ctx context.Context, to net.Addr, path []byte, digests []Digest) ([]Message, []Digest, error) {
+	atomic.AddInt64(client.digests, int64(len(digests)))
+	return client.MockClient.Sync(ctx, to, path, digests)
+}
+
+var _ = Describe("Tree", func() {
+
+	newTree := func(messages ...Message) Tree {
+		tree, err := NewTree(testutils.NewMockMessages())
+		Expect(err).ShouldNot(HaveOccurred())
+		for _, message := range messages {
+			Expect(tree.InsertMessage(message)).ShouldNot(HaveOccurred())
+		}
+		return tree
+	}
+
+	testMessages := func(n int) []Message {
+		messages := make([]Message, n)
+		for i := range messages {
+			messages[i] = signedMessage("owner", 1, fmt.Sprintf("key %v", i), "value")
+		}
+		return messages
+	}
+
+	Context("when hashing messages", func() {
+
+		It("should have the same root regardless of insertion order", func() {
+			messages := testMessages(100)
+			reversed := make([]Message, len(messages))
+			for i := range messages {
+				reversed[len(messages)-1-i] = messages[i]
+			}
+
+			hashes, err := newTree(messages...).Hashes(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			reversedHashes, err := newTree(reversed...).Hashes(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(hashes).Should(Equal(reversedHashes))
+		})
+
+		It("should have a different root when a nonce is different", func() {
+			messages := testMessages(100)
+			tree := newTree(messages...)
+			otherTree := newTree(messages...)
+			Expect(otherTree.InsertMessage(signedMessage("owner", 2, "key 0", "value"))).ShouldNot(HaveOccurred())
+
+			hashes, err := tree.Hashes(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			otherHashes, err := otherTree.Hashes(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(hashes).ShouldNot(Equal(otherHashes))
+		})
+
+		It("should have empty children when there are no messages", func() {
+			hashes, err := newTree().Hashes(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(hashes)).Should(Equal(TreeWidth))
+			for _, hash := range hashes {
+				Expect(hash).Should(BeNil())
+			}
+		})
+
+		It("should load the messages that already exist", func() {
+			messages := testutils.NewMockMessages()
+			for _, message := range testMessages(10) {
+				Expect(messages.InsertMessage(message)).ShouldNot(HaveOccurred())
+			}
+			tree, err := NewTree(messages)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			hashes, err := tree.Hashes(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			otherHashes, err := newTree(testMessages(10)...).Hashes(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(hashes).Should(Equal(otherHashes))
+		})
+
+		It("should return an error for an invalid path", func() {
+			tree := newTree()
+			_, err := tree.Hashes(make([]byte, TreeDepth))
+			Expect(err).Should(HaveOccurred())
+			_, err = tree.Hashes([]byte{TreeWidth})
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when listing digests", func() {
+
+		It("should return the digests in the subtree of a path", func() {
+			messages := testMessages(100)
+			tree := newTree(messages...)
+
+			total := 0
+			for i := 0; i < TreeWidth; i++ {
+				digests, err := tree.Digests([]byte{byte(i)})
+				Expect(err).ShouldNot(HaveOccurred())
+				total += len(digests)
+			}
+			Expect(total).Should(Equal(len(messages)))
+
+			digests, err := tree.Digests(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(digests)).Should(Equal(len(messages)))
+		})
+	})
+
+	Context("when synchronising with a peer", func() {
+
+		It("should only exchange the digests of subtrees that differ", func() {
+			shared := testMessages(200)
+			digests := int64(0)
+			client := countingClient{testutils.NewMockClient(), &digests}
+			books := make([]addr.Book, 2)
+			peers := make([]net.Addr, 2)
+			trees := make([]Tree, 2)
+			gossipers := make([]Gossiper, 2)
+			for i := range gossipers {
+				book, err := addr.NewBook(testutils.NewMockAddrs())
+				Expect(err).ShouldNot(HaveOccurred())
+				books[i] = book
+				peers[i] = testutils.RandomAddr()
+				trees[i] = newTree(shared...)
+				gossipers[i] = NewGossiper(books[i], 1, testutils.MockSinger{}, testutils.MockStrictVerifier{}, nil, nil, nil, client, trees[i])
+				client.Connect(peers[i], gossipers[i])
+			}
+			Expect(books[0].InsertAddr(peers[1])).ShouldNot(HaveOccurred())
+
+			onlyFirst := signedMessage("owner", 1, "first", "value")
+			onlySecond := signedMessage("owner", 1, "second", "value")
+			newer := signedMessage("owner", 2, "key 0", "another value")
+			Expect(trees[0].InsertMessage(onlyFirst)).ShouldNot(HaveOccurred())
+			Expect(trees[1].InsertMessage(onlySecond)).ShouldNot(HaveOccurred())
+			Expect(trees[1].InsertMessage(newer)).ShouldNot(HaveOccurred())
+
+			Expect(gossipers[0].Synchronise(context.Background())).ShouldNot(HaveOccurred())
+			Expect(atomic.LoadInt64(&digests)).Should(BeNumerically("<=", 3))
+
+			hashes, err := trees[0].Hashes(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			otherHashes, err := trees[1].Hashes(nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(hashes).Should(Equal(otherHashes))
+			for _, tree := range trees {
+				for _, message := range []Message{onlyFirst, onlySecond, newer} {
+					stored, err := tree.Message(message.Key)
+					Expect(err).ShouldNot(HaveOccurred())
+					Expect(stored).Should(Equal(message))
+				}
+			}
+		})
+	})
+})
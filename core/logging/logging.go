@@ -0,0 +1,172 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+)
+
+// A Level is the severity of a log entry.
+type Level uint8
+
+const (
+	// LevelDebug entries describe failures that are expected during normal
+	// operation, such as a peer that cannot be reached.
+	LevelDebug Level = iota
+
+	// LevelInfo entries describe normal operation.
+	LevelInfo
+
+	// LevelWarn entries describe failures that are caused by a remote peer,
+	// such as an invalid Message.
+	LevelWarn
+
+	// LevelError entries describe failures that are caused locally.
+	LevelError
+)
+
+// String implements the `fmt.Stringer` interface.
+func (level Level) String() string {
+	switch level {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", level)
+	}
+}
+
+// A Field is a key-value pair that adds structured context to a log entry.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Peer returns a Field for the `net.Addr` of a remote peer.
+func Peer(addr net.Addr) Field {
+	if addr == nil {
+		return Field{"peer", nil}
+	}
+	return Field{"peer", addr.String()}
+}
+
+// Key returns a Field for the key of a Message. The key is hex encoded.
+func Key(key []byte) Field {
+	return Field{"key", fmt.Sprintf("%x", key)}
+}
+
+// Nonce returns a Field for the nonce of a Message.
+func Nonce(nonce uint64) Field {
+	return Field{"nonce", nonce}
+}
+
+// Err returns a Field for an error.
+func Err(err error) Field {
+	return Field{"error", err}
+}
+
+// Any returns a Field for any other value.
+func Any(key string, value interface{}) Field {
+	return Field{key, value}
+}
+
+// A Logger writes log entries that have a Level, a message, and Fields.
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+}
+
+type stdLogger struct {
+	logger *log.Logger
+	level  Level
+}
+
+// NewStdLogger returns a Logger that writes to a `log.Logger`, or to the
+// standard logger of the `log` package if it is nil. Entries with a lower Level
+// than the `level` are discarded. Entries are written as the Level in square
+// brackets, followed by the message and `key=value` pairs for the Fields.
+func NewStdLogger(logger *log.Logger, level Level) Logger {
+	return stdLogger{
+		logger: logger,
+		level:  level,
+	}
+}
+
+// Debug implements the Logger interface.
+func (logger stdLogger) Debug(msg string, fields ...Field) {
+	logger.write(LevelDebug, msg, fields)
+}
+
+// Info implements the Logger interface.
+func (logger stdLogger) Info(msg string, fields ...Field) {
+	logger.write(LevelInfo, msg, fields)
+}
+
+// Warn implements the Logger interface.
+func (logger stdLogger) Warn(msg string, fields ...Field) {
+	logger.write(LevelWarn, msg, fields)
+}
+
+// Error implements the Logger interface.
+func (logger stdLogger) Error(msg string, fields ...Field) {
+	logger.write(LevelError, msg, fields)
+}
+
+func (logger stdLogger) write(level Level, msg string, fields []Field) {
+	if level < logger.level {
+		return
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "[%v] %v", level, msg)
+	for _, field := range fields {
+		value := fmt.Sprintf("%v", field.Value)
+		if value == "" || strings.ContainsAny(value, " =\"") {
+			value = fmt.Sprintf("%q", value)
+		}
+		fmt.Fprintf(buf, " %v=%v", field.Key, value)
+	}
+
+	if logger.logger == nil {
+		log.Print(buf.String())
+		return
+	}
+	logger.logger.Print(buf.String())
+}
+
+type nopLogger struct {
+}
+
+// NewNopLogger returns a Logger that discards every entry.
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+// Debug implements the Logger interface.
+func (nopLogger) Debug(msg string, fields ...Field) {}
+
+// Info implements the Logger interface.
+func (nopLogger) Info(msg string, fields ...Field) {}
+
+// Warn implements the Logger interface.
+func (nopLogger) Warn(msg string, fields ...Field) {}
+
+// Error implements the Logger interface.
+func (nopLogger) Error(msg string, fields ...Field) {}
+
+// NewDefaultLogger returns the Logger that is used when no Logger is given. It
+// writes entries of LevelInfo and above to the standard logger of the `log`
+// package.
+func NewDefaultLogger() Logger {
+	return NewStdLogger(nil, LevelInfo)
+}
@@ -0,0 +1,51 @@
+package logging_test
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/logging"
+)
+
+var _ = Describe("Logger", func() {
+
+	Context("when writing to a standard logger", func() {
+
+		It("should write the level, message and fields", func() {
+			buf := new(bytes.Buffer)
+			logger := NewStdLogger(log.New(buf, "", 0), LevelDebug)
+			peer, err := net.ResolveTCPAddr("tcp", "127.0.0.1:18514")
+			Expect(err).ShouldNot(HaveOccurred())
+
+			logger.Error("cannot send message", Peer(peer), Key([]byte{0xab, 0xcd}), Nonce(2), Err(errors.New("connection refused")))
+			Expect(buf.String()).Should(Equal("[error] cannot send message peer=127.0.0.1:18514 key=abcd nonce=2 error=\"connection refused\"\n"))
+		})
+
+		It("should discard entries below its level", func() {
+			buf := new(bytes.Buffer)
+			logger := NewStdLogger(log.New(buf, "", 0), LevelWarn)
+
+			logger.Debug("debug")
+			logger.Info("info")
+			Expect(buf.String()).Should(BeEmpty())
+			logger.Warn("warn")
+			logger.Error("error", Any("empty", ""))
+			Expect(buf.String()).Should(Equal("[warn] warn\n[error] error empty=\"\"\n"))
+		})
+	})
+
+	Context("when using a no-op logger", func() {
+
+		It("should discard every entry", func() {
+			logger := NewNopLogger()
+			logger.Debug("debug", Nonce(1))
+			logger.Info("info")
+			logger.Warn("warn")
+			logger.Error("error", Err(errors.New("error")))
+		})
+	})
+})
@@ -0,0 +1,517 @@
+package membership
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/core/logging"
+	"github.com/republicprotocol/co-go"
+)
+
+// MaxPiggyback is the maximum number of Updates that are piggybacked onto a
+// single ping, or a single response to a ping.
+const MaxPiggyback = 8
+
+// Retransmits is the number of times that an Update is piggybacked before it
+// is no longer disseminated.
+const Retransmits = 6
+
+// A State of a member.
+type State uint8
+
+// Values for the State of a member. The zero State is not used.
+const (
+	Alive   State = 1
+	Suspect State = 2
+	Dead    State = 3
+)
+
+// An Update declares the State of a member at an incarnation. Only the member
+// itself can increase its incarnation, which it does to refute suspicion.
+type Update struct {
+	Addr        net.Addr
+	State       State
+	Incarnation uint64
+}
+
+// An Observer is notified whenever the State of a member changes.
+type Observer interface {
+	Notify(update Update) error
+}
+
+// A Client is used to ping remote members.
+type Client interface {
+
+	// Ping a remote `net.Addr`, piggybacking Updates onto the ping. It
+	// returns the Updates that were piggybacked onto the response.
+	Ping(ctx context.Context, to net.Addr, updates []Update) ([]Update, error)
+
+	// PingIndirect asks a remote `net.Addr` to ping the target on behalf of
+	// the caller, piggybacking Updates onto the request. It returns the
+	// Updates that were piggybacked onto the response, and an error if the
+	// target could not be pinged.
+	PingIndirect(ctx context.Context, via, target net.Addr, updates []Update) ([]Update, error)
+}
+
+// A Server responds to pings from remote members. Updates can also be
+// piggybacked onto messages that are exchanged for other purposes, such as
+// gossip, so that they are disseminated faster than by pings alone.
+type Server interface {
+
+	// Ping is called when a remote member pings the Server. It returns the
+	// Updates that are piggybacked onto the response.
+	Ping(ctx context.Context, updates []Update) ([]Update, error)
+
+	// PingIndirect is called when a remote member asks the Server to ping
+	// the target on its behalf. It returns an error if the target could not
+	// be pinged.
+	PingIndirect(ctx context.Context, target net.Addr, updates []Update) ([]Update, error)
+
+	// Piggyback returns the Updates that are piggybacked onto a message
+	// that is sent for another purpose.
+	Piggyback() []Update
+
+	// Apply the Updates that were piggybacked onto a message that was
+	// received for another purpose.
+	Apply(updates []Update)
+}
+
+// Membership detects failed members using the SWIM protocol. It is an
+// `addr.Book` that only samples members that are alive, and it removes members
+// from the underlying `addr.Book` once they are declared dead.
+type Membership interface {
+	addr.Book
+	Server
+
+	// Probe runs one protocol period. It pings a random member, and if the
+	// member does not respond, it asks k other members to ping it
+	// indirectly. If none of them can ping the member, it is suspected.
+	// Members that have been suspected for longer than the suspicion timeout
+	// are declared dead, and members that have been dead for longer than the
+	// suspicion timeout are forgotten.
+	Probe(ctx context.Context) error
+
+	// Member returns the latest Update about a `net.Addr`, and false if the
+	// `net.Addr` is not a member.
+	Member(addr net.Addr) (Update, bool)
+}
+
+type member struct {
+	Update
+
+	// changedAt is the time at which a member was suspected, or declared
+	// dead
+	changedAt time.Time
+}
+
+type broadcast struct {
+	update    Update
+	transmits int
+}
+
+type membership struct {
+	addr.Book
+
+	self             net.Addr
+	client           Client
+	k                int
+	timeout          time.Duration
+	suspicionTimeout time.Duration
+	observer         Observer
+	logger           logging.Logger
+
+	mu          *sync.Mutex
+	incarnation uint64
+	members     map[string]member
+	broadcasts  []*broadcast
+}
+
+// New returns a Membership for the member at the `self` address, that stores
+// members in the `book`. Every `net.Addr` in the `book` is initially alive. A
+// ping that does not complete within the `timeout` is retried indirectly
+// through `k` other members, and members are declared dead after being
+// suspected for the `suspicionTimeout`. The `observer` can be nil. The
+// `logger` can be nil, in which case the default `logging.Logger` is used.
+func New(self net.Addr, book addr.Book, client Client, k int, timeout, suspicionTimeout time.Duration, observer Observer, logger logging.Logger) Membership {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	return &membership{
+		Book: book,
+
+		self:             self,
+		client:           client,
+		k:                k,
+		timeout:          timeout,
+		suspicionTimeout: suspicionTimeout,
+		observer:         observer,
+		logger:           logger,
+
+		mu:         new(sync.Mutex),
+		members:    map[string]member{},
+		broadcasts: []*broadcast{},
+	}
+}
+
+// RunProbes calls `Probe` on the Membership once every period until the
+// context is done. Errors are logged by the `logger`, and do not stop future
+// protocol periods. The `logger` can be nil, in which case the default
+// `logging.Logger` is used.
+func RunProbes(ctx context.Context, membership Membership, period time.Duration, logger logging.Logger) {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := membership.Probe(ctx); err != nil {
+			logger.Error("cannot probe members", logging.Err(err))
+		}
+	}
+}
+
+// InsertAddr implements the `addr.Book` interface. Inserting a `net.Addr`
+// makes it an alive member, even if it was declared dead.
+func (membership *membership) InsertAddr(addr net.Addr) error {
+	membership.mu.Lock()
+	defer membership.mu.Unlock()
+
+	if err := membership.Book.InsertAddr(addr); err != nil {
+		return err
+	}
+	if m, ok := membership.members[addr.String()]; ok && m.State == Dead {
+		delete(membership.members, addr.String())
+	}
+	return nil
+}
+
+// RemoveAddr implements the `addr.Book` interface.
+func (membership *membership) RemoveAddr(addr net.Addr) error {
+	membership.mu.Lock()
+	defer membership.mu.Unlock()
+
+	delete(membership.members, addr.String())
+	return membership.Book.RemoveAddr(addr)
+}
+
+// Addrs implements the `addr.Book` interface. It returns a uniformly random
+// sample of the members that are alive.
+func (membership *membership) Addrs(α int) ([]net.Addr, error) {
+	membership.mu.Lock()
+	defer membership.mu.Unlock()
+
+	return membership.alive(α, nil)
+}
+
+// Member implements the Membership interface.
+func (membership *membership) Member(addr net.Addr) (Update, bool) {
+	membership.mu.Lock()
+	defer membership.mu.Unlock()
+
+	return membership.member(addr)
+}
+
+// Ping implements the Server interface.
+func (membership *membership) Ping(ctx context.Context, updates []Update) ([]Update, error) {
+	membership.apply(updates)
+	return membership.piggyback(), nil
+}
+
+// PingIndirect implements the Server interface.
+func (membership *membership) PingIndirect(ctx context.Context, target net.Addr, updates []Update) ([]Update, error) {
+	membership.apply(updates)
+	if err := membership.ping(ctx, target); err != nil {
+		return nil, err
+	}
+	return membership.piggyback(), nil
+}
+
+// Piggyback implements the Server interface.
+func (membership *membership) Piggyback() []Update {
+	return membership.piggyback()
+}
+
+// Apply implements the Server interface.
+func (membership *membership) Apply(updates []Update) {
+	membership.apply(updates)
+}
+
+// Probe implements the Membership interface.
+func (membership *membership) Probe(ctx context.Context) error {
+	membership.expire()
+
+	membership.mu.Lock()
+	targets, err := membership.Book.Addrs(2)
+	membership.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	var target net.Addr
+	for _, addr := range targets {
+		if addr.String() != membership.self.String() {
+			target = addr
+			break
+		}
+	}
+	if target == nil {
+		return nil
+	}
+
+	if err := membership.ping(ctx, target); err == nil {
+		return nil
+	}
+
+	membership.mu.Lock()
+	vias, err := membership.alive(membership.k, target)
+	membership.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	acks := make([]bool, len(vias))
+	co.ForAll(vias, func(i int) {
+		ctx, cancel := context.WithTimeout(ctx, membership.timeout)
+		defer cancel()
+
+		updates, err := membership.client.PingIndirect(ctx, vias[i], target, membership.piggyback())
+		if err != nil {
+			return
+		}
+		membership.apply(updates)
+		acks[i] = true
+	})
+	for _, ack := range acks {
+		if ack {
+			return nil
+		}
+	}
+
+	membership.mu.Lock()
+	update, ok := membership.member(target)
+	membership.mu.Unlock()
+	if ok {
+		membership.apply([]Update{{Addr: target, State: Suspect, Incarnation: update.Incarnation}})
+	}
+	return nil
+}
+
+// ping the target directly, and apply the Updates in the response.
+func (membership *membership) ping(ctx context.Context, target net.Addr) error {
+	ctx, cancel := context.WithTimeout(ctx, membership.timeout)
+	defer cancel()
+
+	updates, err := membership.client.Ping(ctx, target, membership.piggyback())
+	if err != nil {
+		return err
+	}
+	membership.apply(updates)
+	return nil
+}
+
+// expire forgets members that have been dead for longer than the suspicion
+// timeout, and declares members dead once they have been suspected for longer
+// than the suspicion timeout. Dead members are remembered for a while, so that
+// older Updates that declare them alive are ignored.
+func (membership *membership) expire() {
+	membership.mu.Lock()
+	dead := make([]Update, 0)
+	for key, m := range membership.members {
+		if time.Since(m.changedAt) < membership.suspicionTimeout {
+			continue
+		}
+		switch m.State {
+		case Suspect:
+			dead = append(dead, Update{Addr: m.Addr, State: Dead, Incarnation: m.Incarnation})
+		case Dead:
+			delete(membership.members, key)
+		}
+	}
+	membership.mu.Unlock()
+
+	membership.apply(dead)
+}
+
+// apply Updates that were received from remote members, and notify the
+// Observer about the Updates that changed the State of a member.
+func (membership *membership) apply(updates []Update) {
+	membership.mu.Lock()
+	changes := make([]Update, 0, len(updates))
+	for _, update := range updates {
+		if update.Addr == nil {
+			continue
+		}
+		if membership.applyUpdate(update) {
+			changes = append(changes, update)
+		}
+	}
+	membership.mu.Unlock()
+
+	if membership.observer == nil {
+		return
+	}
+	for _, update := range changes {
+		if err := membership.observer.Notify(update); err != nil {
+			membership.logger.Error("cannot notify observer", logging.Peer(update.Addr), logging.Err(err))
+		}
+	}
+}
+
+// applyUpdate returns true if the Update changed the State of a member. It
+// must be called while holding the lock.
+func (membership *membership) applyUpdate(update Update) bool {
+	if update.Addr.String() == membership.self.String() {
+		// Refute any suspicion about this member by increasing its
+		// incarnation
+		if update.State != Alive && update.Incarnation >= membership.incarnation {
+			membership.incarnation = update.Incarnation + 1
+			membership.enqueue(Update{Addr: membership.self, State: Alive, Incarnation: membership.incarnation})
+		}
+		return false
+	}
+
+	current, ok := membership.member(update.Addr)
+	if ok && !supersedes(update, current) {
+		return false
+	}
+	if !ok && update.State != Alive {
+		return false
+	}
+
+	switch update.State {
+	case Alive:
+		if !ok || current.State == Dead {
+			if err := membership.Book.InsertAddr(update.Addr); err != nil {
+				membership.logger.Error("cannot insert member", logging.Peer(update.Addr), logging.Err(err))
+				return false
+			}
+		}
+		membership.members[update.Addr.String()] = member{Update: update}
+	case Suspect:
+		membership.members[update.Addr.String()] = member{Update: update, changedAt: time.Now()}
+	case Dead:
+		if err := membership.Book.RemoveAddr(update.Addr); err != nil {
+			membership.logger.Error("cannot remove member", logging.Peer(update.Addr), logging.Err(err))
+			return false
+		}
+		membership.members[update.Addr.String()] = member{Update: update, changedAt: time.Now()}
+	default:
+		return false
+	}
+	membership.enqueue(update)
+	return true
+}
+
+// member returns the latest Update about a `net.Addr`. Members in the
+// `addr.Book` that have no Update are alive at the zero incarnation. It must
+// be called while holding the lock.
+func (membership *membership) member(addr net.Addr) (Update, bool) {
+	if m, ok := membership.members[addr.String()]; ok {
+		return m.Update, true
+	}
+	if _, ok := membership.Book.Record(addr); ok {
+		return Update{Addr: addr, State: Alive}, true
+	}
+	return Update{}, false
+}
+
+// alive returns a uniformly random sample of at most α alive members, other
+// than this member and the excluded `net.Addr`. It must be called while
+// holding the lock.
+func (membership *membership) alive(α int, excluded net.Addr) ([]net.Addr, error) {
+	skip := map[string]struct{}{membership.self.String(): {}}
+	if excluded != nil {
+		skip[excluded.String()] = struct{}{}
+	}
+	for key, m := range membership.members {
+		if m.State == Suspect {
+			skip[key] = struct{}{}
+		}
+	}
+
+	// Sampling enough extra `net.Addr` to account for the skipped ones
+	// keeps the sample of alive members uniformly random
+	addrs, err := membership.Book.Addrs(α + len(skip))
+	if err != nil {
+		return nil, err
+	}
+	alive := make([]net.Addr, 0, α)
+	for _, addr := range addrs {
+		if len(alive) >= α {
+			break
+		}
+		if _, ok := skip[addr.String()]; ok {
+			continue
+		}
+		alive = append(alive, addr)
+	}
+	return alive, nil
+}
+
+// enqueue an Update to be piggybacked, replacing any older Update about the
+// same member. It must be called while holding the lock.
+func (membership *membership) enqueue(update Update) {
+	for i, b := range membership.broadcasts {
+		if b.update.Addr.String() == update.Addr.String() {
+			membership.broadcasts = append(membership.broadcasts[:i], membership.broadcasts[i+1:]...)
+			break
+		}
+	}
+	membership.broadcasts = append(membership.broadcasts, &broadcast{update: update})
+}
+
+// piggyback returns the Updates that have been transmitted the least, and
+// stops disseminating Updates once they have been transmitted enough times.
+func (membership *membership) piggyback() []Update {
+	membership.mu.Lock()
+	defer membership.mu.Unlock()
+
+	sort.SliceStable(membership.broadcasts, func(i, j int) bool {
+		return membership.broadcasts[i].transmits < membership.broadcasts[j].transmits
+	})
+
+	updates := make([]Update, 0, MaxPiggyback)
+	for _, b := range membership.broadcasts {
+		if len(updates) >= MaxPiggyback {
+			break
+		}
+		updates = append(updates, b.update)
+		b.transmits++
+	}
+
+	broadcasts := membership.broadcasts[:0]
+	for _, b := range membership.broadcasts {
+		if b.transmits < Retransmits {
+			broadcasts = append(broadcasts, b)
+		}
+	}
+	membership.broadcasts = broadcasts
+	return updates
+}
+
+// supersedes returns true if the Update overrides the current Update about
+// the same member.
+func supersedes(update, current Update) bool {
+	switch update.State {
+	case Alive:
+		return update.Incarnation > current.Incarnation
+	case Suspect:
+		if current.State == Alive {
+			return update.Incarnation >= current.Incarnation
+		}
+		return current.State == Suspect && update.Incarnation > current.Incarnation
+	case Dead:
+		return current.State != Dead && update.Incarnation >= current.Incarnation
+	default:
+		return false
+	}
+}
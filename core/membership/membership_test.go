@@ -0,0 +1,236 @@
+package membership_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/membership"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+// network delivers pings between Memberships in memory. Members can be taken
+// down, and links between members can be blocked.
+type network struct {
+	mu      *sync.Mutex
+	members map[string]Membership
+	down    map[string]bool
+	blocked map[[2]string]bool
+}
+
+func newNetwork() *network {
+	return &network{
+		mu:      new(sync.Mutex),
+		members: map[string]Membership{},
+		down:    map[string]bool{},
+		blocked: map[[2]string]bool{},
+	}
+}
+
+func (network *network) server(from, to net.Addr) (Server, error) {
+	network.mu.Lock()
+	defer network.mu.Unlock()
+
+	if network.down[to.String()] || network.blocked[[2]string{from.String(), to.String()}] {
+		return nil, errors.New("cannot reach member")
+	}
+	return network.members[to.String()], nil
+}
+
+// client returns a Client that pings from the `net.Addr`.
+func (network *network) client(from net.Addr) Client {
+	return networkClient{network, from}
+}
+
+type networkClient struct {
+	network *network
+	from    net.Addr
+}
+
+func (client networkClient) Ping(ctx context.Context, to net.Addr, updates []Update) ([]Update, error) {
+	server, err := client.network.server(client.from, to)
+	if err != nil {
+		return nil, err
+	}
+	return server.Ping(ctx, updates)
+}
+
+func (client networkClient) PingIndirect(ctx context.Context, via, target net.Addr, updates []Update) ([]Update, error) {
+	server, err := client.network.server(client.from, via)
+	if err != nil {
+		return nil, err
+	}
+	return server.PingIndirect(ctx, target, updates)
+}
+
+// recordingObserver records the Updates that it is notified about.
+type recordingObserver struct {
+	mu      *sync.Mutex
+	updates *[]Update
+}
+
+func (observer recordingObserver) Notify(update Update) error {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	*observer.updates = append(*observer.updates, update)
+	return nil
+}
+
+func (observer recordingObserver) States() []State {
+	observer.mu.Lock()
+	defer observer.mu.Unlock()
+	states := make([]State, len(*observer.updates))
+	for i, update := range *observer.updates {
+		states[i] = update.State
+	}
+	return states
+}
+
+var _ = Describe("Membership", func() {
+
+	// init returns n Memberships that know about each other, and that
+	// declare suspects dead after the suspicion timeout.
+	init := func(n int, suspicionTimeout time.Duration) (*network, []net.Addr, []Membership, []recordingObserver) {
+		network := newNetwork()
+		addrs := make([]net.Addr, n)
+		for i := range addrs {
+			addrs[i] = testutils.RandomAddr()
+		}
+
+		members := make([]Membership, n)
+		observers := make([]recordingObserver, n)
+		for i := range members {
+			book, err := addr.NewBook(testutils.NewMockAddrs())
+			Expect(err).ShouldNot(HaveOccurred())
+			for j := range addrs {
+				if i != j {
+					Expect(book.InsertAddr(addrs[j])).ShouldNot(HaveOccurred())
+				}
+			}
+			observers[i] = recordingObserver{new(sync.Mutex), new([]Update)}
+			members[i] = New(addrs[i], book, network.client(addrs[i]), 3, time.Second, suspicionTimeout, observers[i], nil)
+			network.members[addrs[i].String()] = members[i]
+		}
+		return network, addrs, members, observers
+	}
+
+	Context("when a member does not respond", func() {
+
+		It("should suspect the member and then declare it dead", func() {
+			network, addrs, members, observers := init(2, 0)
+			network.down[addrs[1].String()] = true
+
+			Expect(members[0].Probe(context.Background())).ShouldNot(HaveOccurred())
+			update, ok := members[0].Member(addrs[1])
+			Expect(ok).Should(BeTrue())
+			Expect(update.State).Should(Equal(Suspect))
+			Expect(members[0].Addrs(1)).Should(BeEmpty())
+
+			Expect(members[0].Probe(context.Background())).ShouldNot(HaveOccurred())
+			update, ok = members[0].Member(addrs[1])
+			Expect(ok).Should(BeTrue())
+			Expect(update.State).Should(Equal(Dead))
+			_, ok = members[0].Record(addrs[1])
+			Expect(ok).Should(BeFalse())
+
+			Expect(observers[0].States()).Should(Equal([]State{Suspect, Dead}))
+		})
+
+		It("should forget the member once it has been dead for the suspicion timeout", func() {
+			network, addrs, members, _ := init(2, 10*time.Millisecond)
+			network.down[addrs[1].String()] = true
+
+			Expect(members[0].Probe(context.Background())).ShouldNot(HaveOccurred())
+			time.Sleep(10 * time.Millisecond)
+			Expect(members[0].Probe(context.Background())).ShouldNot(HaveOccurred())
+			update, ok := members[0].Member(addrs[1])
+			Expect(ok).Should(BeTrue())
+			Expect(update.State).Should(Equal(Dead))
+
+			time.Sleep(10 * time.Millisecond)
+			Expect(members[0].Probe(context.Background())).ShouldNot(HaveOccurred())
+			_, ok = members[0].Member(addrs[1])
+			Expect(ok).Should(BeFalse())
+		})
+
+		It("should not suspect a member that can be pinged indirectly", func() {
+			network, addrs, members, observers := init(3, 0)
+			network.blocked[[2]string{addrs[0].String(), addrs[1].String()}] = true
+
+			for i := 0; i < 20; i++ {
+				Expect(members[0].Probe(context.Background())).ShouldNot(HaveOccurred())
+			}
+			Expect(members[0].Addrs(2)).Should(ConsistOf(addrs[1], addrs[2]))
+			Expect(observers[0].States()).Should(BeEmpty())
+		})
+	})
+
+	Context("when a suspected member responds", func() {
+
+		It("should refute the suspicion with a higher incarnation", func() {
+			network, addrs, members, observers := init(2, time.Hour)
+			network.down[addrs[1].String()] = true
+			Expect(members[0].Probe(context.Background())).ShouldNot(HaveOccurred())
+			Expect(members[0].Addrs(1)).Should(BeEmpty())
+
+			network.down[addrs[1].String()] = false
+			Expect(members[0].Probe(context.Background())).ShouldNot(HaveOccurred())
+			update, ok := members[0].Member(addrs[1])
+			Expect(ok).Should(BeTrue())
+			Expect(update.State).Should(Equal(Alive))
+			Expect(update.Incarnation).Should(Equal(uint64(1)))
+			Expect(members[0].Addrs(1)).Should(Equal([]net.Addr{addrs[1]}))
+
+			Expect(observers[0].States()).Should(Equal([]State{Suspect, Alive}))
+		})
+	})
+
+	Context("when receiving updates", func() {
+
+		It("should disseminate updates to other members", func() {
+			network, addrs, members, _ := init(3, time.Hour)
+			network.down[addrs[2].String()] = true
+
+			for i := 0; i < 50; i++ {
+				if update, _ := members[1].Member(addrs[2]); update.State == Suspect {
+					break
+				}
+				Expect(members[0].Probe(context.Background())).ShouldNot(HaveOccurred())
+			}
+			update, ok := members[1].Member(addrs[2])
+			Expect(ok).Should(BeTrue())
+			Expect(update.State).Should(Equal(Suspect))
+		})
+
+		It("should learn about new members that are alive", func() {
+			_, addrs, members, observers := init(2, time.Hour)
+			joined := testutils.RandomAddr()
+
+			_, err := members[0].Ping(context.Background(), []Update{{Addr: joined, State: Alive}})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(members[0].Addrs(2)).Should(ConsistOf(addrs[1], joined))
+			Expect(observers[0].States()).Should(Equal([]State{Alive}))
+		})
+
+		It("should ignore updates that are older than the current state", func() {
+			_, addrs, members, _ := init(2, time.Hour)
+			_, err := members[0].Ping(context.Background(), []Update{{Addr: addrs[1], State: Alive, Incarnation: 2}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			_, err = members[0].Ping(context.Background(), []Update{
+				{Addr: addrs[1], State: Suspect, Incarnation: 1},
+				{Addr: addrs[1], State: Dead, Incarnation: 1},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			update, _ := members[0].Member(addrs[1])
+			Expect(update.State).Should(Equal(Alive))
+			Expect(update.Incarnation).Should(Equal(uint64(2)))
+		})
+	})
+})
@@ -0,0 +1,418 @@
+package view
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/republicprotocol/babble-go/core/addr"
+	"github.com/republicprotocol/babble-go/core/logging"
+)
+
+// A Client is used to shuffle views with remote peers.
+type Client interface {
+
+	// Shuffle sends a sample of `net.Addr` to a remote `net.Addr`. It returns
+	// a sample of `net.Addr` from the passive view of the remote peer.
+	Shuffle(ctx context.Context, to net.Addr, addrs []net.Addr) ([]net.Addr, error)
+}
+
+// A Server responds to shuffles from remote peers.
+type Server interface {
+
+	// Shuffle is called when a remote peer shuffles its view with the Server.
+	// The Server integrates the `addrs` into its passive view, and returns a
+	// sample of its passive view of the same size.
+	Shuffle(ctx context.Context, addrs []net.Addr) ([]net.Addr, error)
+}
+
+// A View is an `addr.Book` that only keeps a partial view of the network, as
+// in HyParView. It has a small active view, from which `net.Addr` are sampled
+// for gossiping, and a larger passive view of backups that replace failed
+// peers in the active view. Views are kept fresh by periodically shuffling
+// them with random peers. Every `net.Addr` in either view is stored in an
+// underlying `addr.Book`, and every other `net.Addr` is forgotten.
+type View interface {
+	addr.Book
+	Server
+
+	// ShuffleView runs one round of shuffling with a random peer from the
+	// active view. It sends this peer, and a sample of both views, to the
+	// remote peer, and integrates its response into the passive view.
+	ShuffleView(ctx context.Context) error
+
+	// Active returns the `net.Addr` in the active view.
+	Active() []net.Addr
+
+	// Passive returns the `net.Addr` in the passive view.
+	Passive() []net.Addr
+}
+
+type view struct {
+	addr.Book
+
+	self        net.Addr
+	client      Client
+	activeSize  int
+	passiveSize int
+	shuffleSize int
+	logger      logging.Logger
+
+	mu      *sync.Mutex
+	rand    *rand.Rand
+	active  []net.Addr
+	passive []net.Addr
+}
+
+// New returns a View for the peer at the `self` address, that stores its views
+// in the `addrs` store. The active view holds at most `activeSize` peers, and
+// the passive view holds at most `passiveSize` peers. Each shuffle sends at
+// most `shuffleSize` peers from each view. Stored `net.Addr` that do not fit
+// into either view are removed from the store. It returns an error if any of
+// the sizes are negative. The `logger` can be nil, in which case the default
+// `logging.Logger` is used.
+func New(self net.Addr, addrs addr.Addrs, client Client, activeSize, passiveSize, shuffleSize int, logger logging.Logger) (View, error) {
+	if activeSize < 0 || passiveSize < 0 || shuffleSize < 0 {
+		return nil, fmt.Errorf("expected non-negative sizes, got %v, %v and %v", activeSize, passiveSize, shuffleSize)
+	}
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	book, err := addr.NewBook(addrs)
+	if err != nil {
+		return nil, err
+	}
+	knownAddrs, err := addrs.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	view := &view{
+		Book: book,
+
+		self:        self,
+		client:      client,
+		activeSize:  activeSize,
+		passiveSize: passiveSize,
+		shuffleSize: shuffleSize,
+		logger:      logger,
+
+		mu:      new(sync.Mutex),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		active:  make([]net.Addr, 0, activeSize),
+		passive: make([]net.Addr, 0, passiveSize),
+	}
+	for _, i := range view.rand.Perm(len(knownAddrs)) {
+		switch {
+		case len(view.active) < activeSize && !view.isSelf(knownAddrs[i]):
+			view.active = append(view.active, knownAddrs[i])
+		case len(view.passive) < passiveSize && !view.isSelf(knownAddrs[i]):
+			view.passive = append(view.passive, knownAddrs[i])
+		default:
+			if err := book.RemoveAddr(knownAddrs[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return view, nil
+}
+
+// RunShuffle calls `ShuffleView` on the View once every period until the
+// context is done. Errors are logged by the `logger`, and do not stop future
+// rounds. The `logger` can be nil, in which case the default `logging.Logger`
+// is used.
+func RunShuffle(ctx context.Context, view View, period time.Duration, logger logging.Logger) {
+	if logger == nil {
+		logger = logging.NewDefaultLogger()
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := view.ShuffleView(ctx); err != nil {
+			logger.Debug("cannot shuffle view", logging.Err(err))
+		}
+	}
+}
+
+// InsertAddr implements the `addr.Book` interface. The `net.Addr` is inserted
+// into the active view if it is not full, otherwise it is inserted into the
+// passive view.
+func (view *view) InsertAddr(addr net.Addr) error {
+	view.mu.Lock()
+	defer view.mu.Unlock()
+
+	if view.isSelf(addr) {
+		return nil
+	}
+	if indexOf(view.active, addr) >= 0 || indexOf(view.passive, addr) >= 0 {
+		return view.Book.InsertAddr(addr)
+	}
+	if len(view.active) < view.activeSize {
+		if err := view.Book.InsertAddr(addr); err != nil {
+			return err
+		}
+		view.active = append(view.active, addr)
+		return nil
+	}
+	return view.insertPassive(addr, nil)
+}
+
+// RemoveAddr implements the `addr.Book` interface. A `net.Addr` that is
+// removed from the active view is replaced by a random `net.Addr` from the
+// passive view.
+func (view *view) RemoveAddr(addr net.Addr) error {
+	view.mu.Lock()
+	defer view.mu.Unlock()
+
+	if err := view.Book.RemoveAddr(addr); err != nil {
+		return err
+	}
+	view.passive = remove(view.passive, addr)
+	if i := indexOf(view.active, addr); i >= 0 {
+		view.active = remove(view.active, addr)
+		view.promote()
+	}
+	return nil
+}
+
+// Addrs implements the `addr.Book` interface. It returns a uniformly random
+// sample of the active view.
+func (view *view) Addrs(α int) ([]net.Addr, error) {
+	view.mu.Lock()
+	defer view.mu.Unlock()
+
+	return view.sample(view.active, α), nil
+}
+
+// Failed implements the `addr.Book` interface. A `net.Addr` in the active view
+// that fails is moved to the passive view, and replaced by a random `net.Addr`
+// from the passive view. A `net.Addr` that is evicted from the underlying
+// `addr.Book` is removed from both views.
+func (view *view) Failed(addr net.Addr) error {
+	view.mu.Lock()
+	defer view.mu.Unlock()
+
+	if err := view.Book.Failed(addr); err != nil {
+		return err
+	}
+	i := indexOf(view.active, addr)
+	if _, ok := view.Book.Record(addr); !ok {
+		view.passive = remove(view.passive, addr)
+		view.active = remove(view.active, addr)
+		if i >= 0 {
+			view.promote()
+		}
+		return nil
+	}
+	if i >= 0 {
+		view.active = remove(view.active, addr)
+		view.promote()
+		return view.insertPassive(addr, nil)
+	}
+	return nil
+}
+
+// Expire implements the `addr.Book` interface.
+func (view *view) Expire(lastSeen time.Time) error {
+	view.mu.Lock()
+	defer view.mu.Unlock()
+
+	if err := view.Book.Expire(lastSeen); err != nil {
+		return err
+	}
+	view.passive = view.known(view.passive)
+	view.active = view.known(view.active)
+	for len(view.active) < view.activeSize && len(view.passive) > 0 {
+		view.promote()
+	}
+	return nil
+}
+
+// Active implements the View interface.
+func (view *view) Active() []net.Addr {
+	view.mu.Lock()
+	defer view.mu.Unlock()
+
+	return append([]net.Addr{}, view.active...)
+}
+
+// Passive implements the View interface.
+func (view *view) Passive() []net.Addr {
+	view.mu.Lock()
+	defer view.mu.Unlock()
+
+	return append([]net.Addr{}, view.passive...)
+}
+
+// Shuffle implements the Server interface.
+func (view *view) Shuffle(ctx context.Context, addrs []net.Addr) ([]net.Addr, error) {
+	view.mu.Lock()
+	defer view.mu.Unlock()
+
+	candidates := make([]net.Addr, 0, len(view.passive))
+	for _, addr := range view.passive {
+		if indexOf(addrs, addr) < 0 {
+			candidates = append(candidates, addr)
+		}
+	}
+	reply := view.sample(candidates, len(addrs))
+	if err := view.integrate(addrs, reply); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// ShuffleView implements the View interface.
+func (view *view) ShuffleView(ctx context.Context) error {
+	view.mu.Lock()
+	peers := view.sample(view.active, 1)
+	if len(peers) == 0 {
+		view.mu.Unlock()
+		return nil
+	}
+	peer := peers[0]
+
+	sent := []net.Addr{view.self}
+	for _, addr := range view.sample(view.active, view.shuffleSize+1) {
+		if len(sent) > view.shuffleSize {
+			break
+		}
+		if addr.String() != peer.String() {
+			sent = append(sent, addr)
+		}
+	}
+	sent = append(sent, view.sample(view.passive, view.shuffleSize)...)
+	view.mu.Unlock()
+
+	received, err := view.client.Shuffle(ctx, peer, sent)
+	if err != nil {
+		if err := view.Failed(peer); err != nil {
+			view.logger.Error("cannot record failure", logging.Peer(peer), logging.Err(err))
+		}
+		return err
+	}
+
+	view.mu.Lock()
+	defer view.mu.Unlock()
+
+	return view.integrate(received, sent)
+}
+
+// integrate `net.Addr` that were received in a shuffle into the passive view.
+// When the passive view is full, `net.Addr` that were sent in the same shuffle
+// are replaced first. It must be called while holding the lock.
+func (view *view) integrate(received, sent []net.Addr) error {
+	for _, addr := range received {
+		if view.isSelf(addr) || indexOf(view.active, addr) >= 0 || indexOf(view.passive, addr) >= 0 {
+			continue
+		}
+		if err := view.insertPassive(addr, sent); err != nil {
+			return err
+		}
+	}
+	for len(view.active) < view.activeSize && len(view.passive) > 0 {
+		view.promote()
+	}
+	return nil
+}
+
+// insertPassive inserts a `net.Addr` into the passive view. When the passive
+// view is full, a `net.Addr` that is in `preferred` is evicted if possible,
+// otherwise a random `net.Addr` is evicted. It must be called while holding
+// the lock.
+func (view *view) insertPassive(addr net.Addr, preferred []net.Addr) error {
+	if view.passiveSize <= 0 {
+		return nil
+	}
+	if len(view.passive) >= view.passiveSize {
+		evicted := view.passive[view.rand.Intn(len(view.passive))]
+		for _, candidate := range view.passive {
+			if indexOf(preferred, candidate) >= 0 {
+				evicted = candidate
+				break
+			}
+		}
+		if err := view.Book.RemoveAddr(evicted); err != nil {
+			return err
+		}
+		view.passive = remove(view.passive, evicted)
+	}
+	if err := view.Book.InsertAddr(addr); err != nil {
+		return err
+	}
+	view.passive = append(view.passive, addr)
+	return nil
+}
+
+// promote a random `net.Addr` from the passive view into the active view. It
+// must be called while holding the lock.
+func (view *view) promote() {
+	if len(view.passive) == 0 {
+		return
+	}
+	addr := view.passive[view.rand.Intn(len(view.passive))]
+	view.passive = remove(view.passive, addr)
+	view.active = append(view.active, addr)
+}
+
+// known returns the `net.Addr` that are still in the underlying `addr.Book`.
+// It must be called while holding the lock.
+func (view *view) known(addrs []net.Addr) []net.Addr {
+	ret := make([]net.Addr, 0, len(addrs))
+	for _, addr := range addrs {
+		if _, ok := view.Book.Record(addr); ok {
+			ret = append(ret, addr)
+		}
+	}
+	return ret
+}
+
+// sample returns a uniformly random sample of at most n `net.Addr`. It must be
+// called while holding the lock.
+func (view *view) sample(addrs []net.Addr, n int) []net.Addr {
+	if n > len(addrs) {
+		n = len(addrs)
+	}
+	if n < 0 {
+		n = 0
+	}
+	ret := make([]net.Addr, 0, n)
+	for _, i := range view.rand.Perm(len(addrs))[:n] {
+		ret = append(ret, addrs[i])
+	}
+	return ret
+}
+
+func (view *view) isSelf(addr net.Addr) bool {
+	return addr.String() == view.self.String()
+}
+
+func indexOf(addrs []net.Addr, addr net.Addr) int {
+	for i := range addrs {
+		if addrs[i].String() == addr.String() {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove returns the `net.Addr` without the `addr`, without modifying the
+// original slice.
+func remove(addrs []net.Addr, addr net.Addr) []net.Addr {
+	ret := make([]net.Addr, 0, len(addrs))
+	for i := range addrs {
+		if addrs[i].String() != addr.String() {
+			ret = append(ret, addrs[i])
+		}
+	}
+	return ret
+}
@@ -0,0 +1,250 @@
+package view_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/republicprotocol/babble-go/core/view"
+
+	"github.com/republicprotocol/babble-go/core/gossip"
+	"github.com/republicprotocol/babble-go/testutils"
+)
+
+// network delivers shuffles between Views in memory. Peers can be taken down.
+type network struct {
+	mu    *sync.Mutex
+	views map[string]View
+	down  map[string]bool
+}
+
+func newNetwork() *network {
+	return &network{
+		mu:    new(sync.Mutex),
+		views: map[string]View{},
+		down:  map[string]bool{},
+	}
+}
+
+func (network *network) Shuffle(ctx context.Context, to net.Addr, addrs []net.Addr) ([]net.Addr, error) {
+	network.mu.Lock()
+	view, ok := network.views[to.String()]
+	down := network.down[to.String()]
+	network.mu.Unlock()
+
+	if !ok || down {
+		return nil, errors.New("cannot reach peer")
+	}
+	return view.Shuffle(ctx, addrs)
+}
+
+func addrAt(i int) net.Addr {
+	addr, err := net.ResolveTCPAddr("tcp", fmt.Sprintf("127.0.0.1:%v", 9000+i))
+	Expect(err).ShouldNot(HaveOccurred())
+	return addr
+}
+
+func addrStrings(addrs []net.Addr) []string {
+	ret := make([]string, len(addrs))
+	for i := range addrs {
+		ret[i] = addrs[i].String()
+	}
+	return ret
+}
+
+var _ = Describe("View", func() {
+
+	Context("when inserting addresses", func() {
+
+		It("should fill the active view before the passive view", func() {
+			view, err := New(addrAt(0), testutils.NewMockAddrs(), newNetwork(), 2, 3, 2, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			for i := 0; i <= 6; i++ {
+				Expect(view.InsertAddr(addrAt(i))).ShouldNot(HaveOccurred())
+			}
+
+			Expect(addrStrings(view.Active())).Should(ConsistOf(addrAt(1).String(), addrAt(2).String()))
+			Expect(view.Passive()).Should(HaveLen(3))
+			addrs, err := view.Addrs(10)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrStrings(addrs)).Should(ConsistOf(addrAt(1).String(), addrAt(2).String()))
+
+			// Evicted addresses are forgotten
+			known := 0
+			for i := 1; i <= 6; i++ {
+				if _, ok := view.Record(addrAt(i)); ok {
+					known++
+				}
+			}
+			Expect(known).Should(Equal(5))
+		})
+
+		It("should forget stored addresses that do not fit into either view", func() {
+			addrs := testutils.NewMockAddrs()
+			for i := 1; i <= 10; i++ {
+				Expect(addrs.InsertAddr(addrAt(i))).ShouldNot(HaveOccurred())
+			}
+			view, err := New(addrAt(0), addrs, newNetwork(), 2, 3, 2, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(view.Active()).Should(HaveLen(2))
+			Expect(view.Passive()).Should(HaveLen(3))
+			stored, err := addrs.Addrs()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(stored).Should(HaveLen(5))
+		})
+	})
+
+	Context("when sampling addresses", func() {
+
+		It("should return no addresses for a negative size", func() {
+			view, err := New(addrAt(0), testutils.NewMockAddrs(), newNetwork(), 2, 3, 2, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(view.InsertAddr(addrAt(1))).ShouldNot(HaveOccurred())
+
+			addrs, err := view.Addrs(-1)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(addrs).Should(BeEmpty())
+		})
+
+		It("should not create a view with negative sizes", func() {
+			for _, sizes := range [][3]int{{-1, 3, 2}, {2, -1, 2}, {2, 3, -1}} {
+				_, err := New(addrAt(0), testutils.NewMockAddrs(), newNetwork(), sizes[0], sizes[1], sizes[2], nil)
+				Expect(err).Should(HaveOccurred())
+			}
+		})
+	})
+
+	Context("when a peer in the active view fails", func() {
+
+		It("should replace it with a peer from the passive view", func() {
+			view, err := New(addrAt(0), testutils.NewMockAddrs(), newNetwork(), 1, 1, 1, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(view.InsertAddr(addrAt(1))).ShouldNot(HaveOccurred())
+			Expect(view.InsertAddr(addrAt(2))).ShouldNot(HaveOccurred())
+
+			Expect(view.Failed(addrAt(1))).ShouldNot(HaveOccurred())
+			Expect(addrStrings(view.Active())).Should(Equal([]string{addrAt(2).String()}))
+			Expect(addrStrings(view.Passive())).Should(Equal([]string{addrAt(1).String()}))
+		})
+
+		It("should remove it from both views when it is evicted", func() {
+			view, err := New(addrAt(0), testutils.NewMockAddrs(), newNetwork(), 1, 1, 1, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(view.InsertAddr(addrAt(1))).ShouldNot(HaveOccurred())
+
+			Expect(view.RemoveAddr(addrAt(1))).ShouldNot(HaveOccurred())
+			Expect(view.Active()).Should(BeEmpty())
+			Expect(view.Passive()).Should(BeEmpty())
+			_, ok := view.Record(addrAt(1))
+			Expect(ok).Should(BeFalse())
+		})
+	})
+
+	Context("when shuffling", func() {
+
+		It("should exchange addresses with a peer from the active view", func() {
+			network := newNetwork()
+			views := make([]View, 2)
+			for i := range views {
+				view, err := New(addrAt(i), testutils.NewMockAddrs(), network, 1, 4, 2, nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				views[i] = view
+				network.views[addrAt(i).String()] = view
+			}
+			Expect(views[0].InsertAddr(addrAt(1))).ShouldNot(HaveOccurred())
+			Expect(views[0].InsertAddr(addrAt(2))).ShouldNot(HaveOccurred())
+			Expect(views[1].InsertAddr(addrAt(3))).ShouldNot(HaveOccurred())
+			Expect(views[1].InsertAddr(addrAt(4))).ShouldNot(HaveOccurred())
+
+			Expect(views[0].ShuffleView(context.Background())).ShouldNot(HaveOccurred())
+			Expect(addrStrings(views[0].Passive())).Should(ConsistOf(addrAt(2).String(), addrAt(4).String()))
+			Expect(addrStrings(views[1].Passive())).Should(ConsistOf(addrAt(0).String(), addrAt(2).String(), addrAt(4).String()))
+		})
+
+		It("should demote a peer that cannot be reached", func() {
+			network := newNetwork()
+			view, err := New(addrAt(0), testutils.NewMockAddrs(), network, 1, 4, 2, nil)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(view.InsertAddr(addrAt(1))).ShouldNot(HaveOccurred())
+			Expect(view.InsertAddr(addrAt(2))).ShouldNot(HaveOccurred())
+
+			Expect(view.ShuffleView(context.Background())).Should(HaveOccurred())
+			Expect(addrStrings(view.Active())).Should(Equal([]string{addrAt(2).String()}))
+			Expect(addrStrings(view.Passive())).Should(Equal([]string{addrAt(1).String()}))
+		})
+
+		It("should keep views bounded and replace peers that are down", func() {
+			n, activeSize, passiveSize := 64, 4, 16
+			network := newNetwork()
+			views := make([]View, n)
+			for i := range views {
+				view, err := New(addrAt(i), testutils.NewMockAddrs(), network, activeSize, passiveSize, 3, nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				for j := 1; j <= activeSize; j++ {
+					Expect(view.InsertAddr(addrAt((i + j) % n))).ShouldNot(HaveOccurred())
+				}
+				views[i] = view
+				network.views[addrAt(i).String()] = view
+			}
+			for i := 0; i < n; i += 4 {
+				network.down[addrAt(i).String()] = true
+			}
+
+			for round := 0; round < 20; round++ {
+				for i := range views {
+					if !network.down[addrAt(i).String()] {
+						views[i].ShuffleView(context.Background())
+					}
+				}
+			}
+
+			for i := range views {
+				if network.down[addrAt(i).String()] {
+					continue
+				}
+				Expect(len(views[i].Active())).Should(BeNumerically("<=", activeSize))
+				Expect(len(views[i].Passive())).Should(BeNumerically("<=", passiveSize))
+				Expect(len(views[i].Passive())).Should(BeNumerically(">", activeSize))
+			}
+		})
+	})
+
+	Context("when used by a gossiper", func() {
+
+		It("should broadcast messages to the active view", func() {
+			n := 16
+			client := testutils.NewMockClient()
+			stores := make([]gossip.Messages, n)
+			gossipers := make([]gossip.Gossiper, n)
+			for i := range gossipers {
+				view, err := New(addrAt(i), testutils.NewMockAddrs(), newNetwork(), 2, 4, 2, nil)
+				Expect(err).ShouldNot(HaveOccurred())
+				for j := 1; j <= 6; j++ {
+					Expect(view.InsertAddr(addrAt((i + j) % n))).ShouldNot(HaveOccurred())
+				}
+				stores[i] = testutils.NewMockMessages()
+				gossipers[i] = gossip.NewGossiper(view, 2, testutils.MockSinger{}, testutils.MockVerifier{}, nil, nil, nil, client, stores[i])
+				client.Connect(addrAt(i), gossipers[i])
+			}
+
+			message := gossip.NewMessage(1, []byte("key"), []byte("value"), nil)
+			Expect(gossipers[0].Broadcast(context.Background(), message)).ShouldNot(HaveOccurred())
+
+			Eventually(func() int {
+				received := 0
+				for _, store := range stores {
+					if message, _ := store.Message([]byte("key")); message.Nonce == 1 {
+						received++
+					}
+				}
+				return received
+			}, 5*time.Second).Should(BeNumerically(">=", n/2))
+		})
+	})
+})
@@ -18,12 +18,14 @@ func RandomAddr() net.Addr {
 }
 
 type MockAddrs struct {
-	addrs map[string]net.Addr
+	addrs   map[string]net.Addr
+	records map[string]addr.Record
 }
 
 func NewMockAddrs() addr.Addrs {
 	return MockAddrs{
-		addrs: map[string]net.Addr{},
+		addrs:   map[string]net.Addr{},
+		records: map[string]addr.Record{},
 	}
 }
 
@@ -32,6 +34,12 @@ func (addrs MockAddrs) InsertAddr(addr net.Addr) error {
 	return nil
 }
 
+func (addrs MockAddrs) RemoveAddr(addr net.Addr) error {
+	delete(addrs.addrs, addr.String())
+	delete(addrs.records, addr.String())
+	return nil
+}
+
 func (addrs MockAddrs) Addrs() ([]net.Addr, error) {
 	ret := make([]net.Addr, 0, len(addrs.addrs))
 	for _, addr := range addrs.addrs {
@@ -40,3 +48,12 @@ func (addrs MockAddrs) Addrs() ([]net.Addr, error) {
 
 	return ret, nil
 }
+
+func (addrs MockAddrs) InsertRecord(addr net.Addr, record addr.Record) error {
+	addrs.records[addr.String()] = record
+	return nil
+}
+
+func (addrs MockAddrs) Record(addr net.Addr) (addr.Record, error) {
+	return addrs.records[addr.String()], nil
+}
@@ -0,0 +1,89 @@
+package testutils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/republicprotocol/babble-go/core/gossip"
+)
+
+// MockClient records every Message that is sent. When a `gossip.Server` is
+// connected to the `net.Addr` of a Message, it is also delivered to that
+// `gossip.Server` instead of being sent over the network.
+type MockClient struct {
+	mu      *sync.Mutex
+	sent    map[string][]gossip.Message
+	servers map[string]gossip.Server
+}
+
+func NewMockClient() MockClient {
+	return MockClient{
+		mu:      new(sync.Mutex),
+		sent:    map[string][]gossip.Message{},
+		servers: map[string]gossip.Server{},
+	}
+}
+
+// Connect a `gossip.Server` to a `net.Addr`.
+func (client MockClient) Connect(to net.Addr, server gossip.Server) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	client.servers[to.String()] = server
+}
+
+func (client MockClient) Send(ctx context.Context, to net.Addr, message gossip.Message) error {
+	client.mu.Lock()
+	client.sent[to.String()] = append(client.sent[to.String()], message)
+	client.mu.Unlock()
+
+	server := client.server(to)
+	if server == nil {
+		return nil
+	}
+	if err := server.Receive(ctx, message); err != nil {
+		return gossip.RejectedError{Err: err}
+	}
+	return nil
+}
+
+func (client MockClient) Sync(ctx context.Context, to net.Addr, path []byte, digests []gossip.Digest) ([]gossip.Message, []gossip.Digest, error) {
+	server := client.server(to)
+	if server == nil {
+		return nil, nil, errors.New("no server connected")
+	}
+	return server.Sync(ctx, path, digests)
+}
+
+func (client MockClient) Hashes(ctx context.Context, to net.Addr, path []byte) ([][]byte, error) {
+	server := client.server(to)
+	if server == nil {
+		return nil, errors.New("no server connected")
+	}
+	return server.Hashes(ctx, path)
+}
+
+func (client MockClient) Peers(ctx context.Context, to net.Addr, n int) ([]net.Addr, error) {
+	server := client.server(to)
+	if server == nil {
+		return nil, errors.New("no server connected")
+	}
+	return server.Peers(ctx, n)
+}
+
+// Sent returns all Messages that have been sent to the `net.Addr`.
+func (client MockClient) Sent(to net.Addr) []gossip.Message {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	return client.sent[to.String()]
+}
+
+func (client MockClient) server(to net.Addr) gossip.Server {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	return client.servers[to.String()]
+}
@@ -1,6 +1,8 @@
 package testutils
 
 import (
+	"bytes"
+	"sort"
 	"sync"
 
 	"github.com/republicprotocol/babble-go/core/gossip"
@@ -26,9 +28,52 @@ func (messages MockMessages) InsertMessage(message gossip.Message) error {
 	return nil
 }
 
+func (messages MockMessages) InsertMessageIfNewer(message gossip.Message, resolver gossip.Resolver) (bool, error) {
+	messages.messageMu.Lock()
+	defer messages.messageMu.Unlock()
+	if !gossip.IsNewer(resolver, messages.messages[string(message.Key)], message) {
+		return false, nil
+	}
+	messages.messages[string(message.Key)] = message
+
+	return true, nil
+}
+
 func (messages MockMessages) Message(key []byte) (gossip.Message, error) {
 	messages.messageMu.Lock()
 	defer messages.messageMu.Unlock()
 
 	return messages.messages[string(key)], nil
 }
+
+func (messages MockMessages) Messages() ([]gossip.Message, error) {
+	messages.messageMu.Lock()
+	defer messages.messageMu.Unlock()
+
+	ret := make([]gossip.Message, 0, len(messages.messages))
+	for _, message := range messages.messages {
+		ret = append(ret, message)
+	}
+
+	return ret, nil
+}
+
+func (messages MockMessages) MessagesWithPrefix(prefix, cursor []byte, limit int) ([]gossip.Message, []byte, error) {
+	messages.messageMu.Lock()
+	defer messages.messageMu.Unlock()
+
+	ret := make([]gossip.Message, 0)
+	for _, message := range messages.messages {
+		if bytes.HasPrefix(message.Key, prefix) && bytes.Compare(message.Key, cursor) >= 0 {
+			ret = append(ret, message)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return bytes.Compare(ret[i].Key, ret[j].Key) < 0
+	})
+
+	if limit > 0 && len(ret) > limit {
+		return ret[:limit], gossip.NextCursor(ret[limit-1].Key), nil
+	}
+	return ret, nil, nil
+}
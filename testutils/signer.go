@@ -1,16 +1,69 @@
 package testutils
 
+import (
+	"bytes"
+	"errors"
+
+	"github.com/republicprotocol/babble-go/core/gossip"
+)
+
+// MockScheme is the signature Scheme of mock signers.
+const MockScheme = gossip.Scheme(0xFF)
+
 type MockSinger struct {
 }
 
+func (signer MockSinger) Scheme() gossip.Scheme {
+	return MockScheme
+}
+
 func (signer MockSinger) Sign(data []byte) ([]byte, error) {
 	return data, nil
 }
 
+// A mock identified signer signs data by prefixing it with its signatory.
+type MockIdentifiedSigner struct {
+	Signatory gossip.Signatory
+}
+
+func (signer MockIdentifiedSigner) Scheme() gossip.Scheme {
+	return MockScheme
+}
+
+func (signer MockIdentifiedSigner) Sign(data []byte) ([]byte, error) {
+	return append(append([]byte{}, signer.Signatory...), data...), nil
+}
+
 // A mock verifier will always return true when verifying signature.
 type MockVerifier struct {
 }
 
-func (verifier MockVerifier) Verify(data []byte, signature []byte) error {
-	return nil
+func (verifier MockVerifier) Verify(scheme gossip.Scheme, data []byte, signature []byte) (gossip.Signatory, error) {
+	return nil, nil
+}
+
+// A mock strict verifier will only return true when the signature was
+// produced by a MockSinger, or a MockIdentifiedSigner, for the same data and
+// with the MockScheme.
+type MockStrictVerifier struct {
+}
+
+func (verifier MockStrictVerifier) Verify(scheme gossip.Scheme, data []byte, signature []byte) (gossip.Signatory, error) {
+	if scheme != MockScheme {
+		return nil, errors.New("invalid scheme")
+	}
+	if !bytes.HasSuffix(signature, data) {
+		return nil, errors.New("invalid signature")
+	}
+	return gossip.Signatory(signature[:len(signature)-len(data)]), nil
+}
+
+// MockDelegations map a delegate to the owner that has delegated to it.
+type MockDelegations map[string]gossip.Signatory
+
+func (delegations MockDelegations) Owner(signatory gossip.Signatory) (gossip.Signatory, error) {
+	if owner, ok := delegations[string(signatory)]; ok {
+		return owner, nil
+	}
+	return signatory, nil
 }